@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"github.com/imperiuse/price_monitor/internal/services/storage/model"
@@ -21,9 +22,18 @@ import (
 	"github.com/imperiuse/price_monitor/internal/logger"
 	"github.com/imperiuse/price_monitor/internal/logger/field"
 	"github.com/imperiuse/price_monitor/internal/servers/http"
+	"github.com/imperiuse/price_monitor/internal/services/alerts"
 	"github.com/imperiuse/price_monitor/internal/services/controllers"
 	"github.com/imperiuse/price_monitor/internal/services/controllers/general/monitor"
+	"github.com/imperiuse/price_monitor/internal/services/controllers/general/sharder"
+	"github.com/imperiuse/price_monitor/internal/services/controllers/general/waiter"
+	"github.com/imperiuse/price_monitor/internal/services/controllers/leadership"
+	"github.com/imperiuse/price_monitor/internal/services/controllers/master/callbacks"
+	"github.com/imperiuse/price_monitor/internal/services/controllers/master/cleaner"
+	"github.com/imperiuse/price_monitor/internal/services/controllers/master/lifecycle"
 	"github.com/imperiuse/price_monitor/internal/services/controllers/master/scanner"
+	"github.com/imperiuse/price_monitor/internal/services/controllers/master/scheduler"
+	"github.com/imperiuse/price_monitor/internal/services/controllers/slave/standby"
 	"github.com/imperiuse/price_monitor/internal/services/market"
 	"github.com/imperiuse/price_monitor/internal/services/storage"
 	"github.com/imperiuse/price_monitor/internal/services/storage/timescaledb"
@@ -104,11 +114,60 @@ func (a *application) run() {
 			) (storage.Storage, error) {
 				return timescaledb.New(storageCfg, logger)
 			},
-			func(cfg http.Config, l *logger.Logger, s storage.Storage) (*http.Server, error) {
-				return http.New(a.env, cfg, l, s)
+			func(
+				cfg http.Config,
+				l *logger.Logger,
+				s storage.Storage,
+				mon *monitor.Controller,
+				ev *alerts.Evaluator,
+				cb *callbacks.ControllerDaemon,
+				w *waiter.Controller,
+			) (*http.Server, error) {
+				return http.New(a.env, cfg, l, s, mon, ev, cb, w)
 			},
+			alerts.New,
 			market.New,
-			scanner.New,
+			leadership.NewBus,
+			sharder.New,
+			sharder.Register,
+			waiter.New,
+			waiter.Register,
+			standby.New,
+			standby.Register,
+			func(
+				cfg controllers.Config,
+				l *logger.Logger,
+				s storage.Storage,
+				m market.Market,
+				sh *sharder.Controller,
+				ev *alerts.Evaluator,
+			) (*scanner.ControllerDaemon, error) {
+				return scanner.New(cfg, l, s, m, sh, ev)
+			},
+			scanner.Register,
+			cleaner.New,
+			cleaner.Register,
+			lifecycle.New,
+			lifecycle.Register,
+			scheduler.New,
+			scheduler.Register,
+			callbacks.New,
+			callbacks.Register,
+			func(
+				cfg controllers.Config,
+				l *logger.Logger,
+				c *consul.Client,
+				s storage.Storage,
+				bus *leadership.Bus,
+				groups controllers.Groups,
+			) (*monitor.Controller, error) {
+				mon, err := monitor.New(a.version, cfg, l, c, s, bus, groups)
+				if err != nil {
+					return nil, fmt.Errorf("can't create monitor: %w", err)
+				}
+
+				return mon, nil
+			},
 		),
 		fx.Invoke(a.start),
 		fx.StartTimeout(a.startTimeout),
@@ -122,12 +181,11 @@ func (a *application) start(
 	lc fx.Lifecycle,
 	globalContext context.Context,
 	globalContextCancel context.CancelFunc,
-	controllersCfg controllers.Config,
 	log *logger.Logger,
 	consul *consul.Client,
 	storage storage.Storage,
 	httpServer *http.Server,
-	scanner *scanner.ControllerDaemon,
+	mon *monitor.Controller,
 ) {
 	lc.Append(fx.Hook{
 		OnStart: func(_ context.Context) error {
@@ -157,18 +215,16 @@ func (a *application) start(
 			case env.Prod:
 			}
 
-			mon, err := monitor.New(a.version, controllersCfg, log, consul, storage,
-				[]controllers.DaemonController{scanner}...)
-			if err != nil {
-				return fmt.Errorf("can't create monitor: %w", err)
-			}
-
 			return mon.Run(globalContext)
 		},
 		OnStop: func(_ context.Context) error {
 			shutDownCtx, shutDownCtxCancel := context.WithTimeout(context.Background(), shutDownTimeout)
 			defer shutDownCtxCancel()
 
+			if err := mon.Handover(shutDownCtx); err != nil && !errors.Is(err, monitor.ErrNotMaster) {
+				log.Error("error on leadership handover", zap.Error(err))
+			}
+
 			consul.Deregister(log, httpServer)
 
 			httpServer.Stop(shutDownCtx)
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"github.com/spf13/viper"
 	"go.uber.org/config"
@@ -12,14 +13,22 @@ import (
 	"github.com/imperiuse/price_monitor/internal/logger"
 	"github.com/imperiuse/price_monitor/internal/servers"
 	"github.com/imperiuse/price_monitor/internal/services"
+	"github.com/imperiuse/price_monitor/internal/services/controllers/master"
 )
 
 //nolint gosec golint
 const (
 	EnvNamePostgresUser     = "PM_POSTGRES_USER"
 	EnvNamePostgresPassword = "PM_POSTGRES_PASSWORD"
+	EnvNameCallbackSecret   = "PM_CALLBACK_SECRET"
+	EnvNameAdminToken       = "PM_ADMIN_TOKEN"
 )
 
+const maskedValue = "***"
+
+// ErrPlaceholderSecret - secret from config is default placeholder.
+var ErrPlaceholderSecret = errors.New("secret is not set")
+
 // Config - main config.
 type Config struct {
 	fx.Out
@@ -50,12 +59,17 @@ func New(appName string, envName env.Var, configPath string, nodeName string) (C
 
 	applyEnvOnConfig(&cfg, appName)
 
-	cfg.Servers.HTTP.Name += "_" + nodeName
+	if envName != env.Dev && cfg.Services.Controllers.Master.Callbacks.Secret == master.PlaceholderCallbackSecret {
+		return cfg, fmt.Errorf("%w: callbacks secret must be set by env %s", ErrPlaceholderSecret,
+			EnvNameCallbackSecret)
+	}
+
 	cfg.Servers.HTTP.NodeID = nodeName
+	cfg.Consul.NodeID = nodeName
 
 	// show config for debug purposes
 	// nolint forbidigo // exception of rule )
-	fmt.Printf("NodeName: %s, AppName: %s; EnvName: %s; Config: %+v", nodeName, appName, envName, cfg)
+	fmt.Printf("NodeName: %s, AppName: %s; EnvName: %s; Config: %+v", nodeName, appName, envName, masked(cfg))
 
 	return cfg, nil
 }
@@ -66,4 +80,21 @@ func applyEnvOnConfig(cfg *Config, appName string) {
 
 	cfg.Services.Storage.Username = v.GetString(EnvNamePostgresUser)
 	cfg.Services.Storage.Password = v.GetString(EnvNamePostgresPassword)
+
+	if secret := v.GetString(EnvNameCallbackSecret); secret != "" {
+		cfg.Services.Controllers.Master.Callbacks.Secret = secret
+	}
+
+	if token := v.GetString(EnvNameAdminToken); token != "" {
+		cfg.Servers.HTTP.AdminToken = token
+	}
+}
+
+// masked - copy of config with masked secrets (for logs).
+func masked(cfg Config) Config {
+	cfg.Services.Storage.Password = maskedValue
+	cfg.Services.Controllers.Master.Callbacks.Secret = maskedValue
+	cfg.Servers.HTTP.AdminToken = maskedValue
+
+	return cfg
 }
@@ -13,9 +13,16 @@ func Test_New(t *testing.T) {
 	assert.NotNil(t, cfg)
 	assert.Nil(t, err)
 
+	_, err = New("core", env.Prod, "../../configs", "")
+	assert.ErrorIs(t, err, ErrPlaceholderSecret, "default callbacks secret is allowed only in dev env")
+
+	t.Setenv(EnvNameCallbackSecret, "secret")
+
 	cfg, err = New("core", env.Prod, "../../configs", "")
 	assert.NotNil(t, cfg)
 	assert.Nil(t, err)
+	assert.Equal(t, "secret", cfg.Services.Controllers.Master.Callbacks.Secret)
+	assert.Equal(t, maskedValue, masked(cfg).Services.Controllers.Master.Callbacks.Secret)
 
 	cfg, err = New("core", env.Test, "../../configs", "")
 	assert.NotNil(t, cfg)
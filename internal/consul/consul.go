@@ -1,12 +1,15 @@
 package consul
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strconv"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/consul/api"
+	jsoniter "github.com/json-iterator/go"
 	"go.uber.org/zap"
 
 	"github.com/imperiuse/price_monitor/internal/helper"
@@ -14,9 +17,11 @@ import (
 	"github.com/imperiuse/price_monitor/internal/logger/field"
 )
 
-var (
-	ErrNilKV = errors.New("nil kv pair struct")
-)
+// MetaNodeID - key of service meta which contains id of app node.
+const MetaNodeID = "node_id"
+
+// ErrNoRegisteredServices - client has not registered any service yet.
+var ErrNoRegisteredServices = errors.New("no registered services")
 
 // RegistrableService - interface which need to impl for Consul register
 type RegistrableService interface {
@@ -41,9 +46,13 @@ type (
 		Tags        []string
 		DNS         []string
 		SessionTTL  string `yaml:"sessionTTL"`
-		WaitTimeout string `yaml:"waitTimeout"`
+		LockDelay   string `yaml:"lockDelay"`   // keys of invalidated session can't be acquired during lock delay
+		WaitTimeout string `yaml:"waitTimeout"` // max wait time of blocking queries
+		NodeID      string `yaml:"-"`           // id of app node (set on start)
 
-		sessionTTL time.Duration
+		sessionTTL  time.Duration
+		lockDelay   time.Duration
+		waitTimeout time.Duration
 	}
 
 	// ApiConsulClientI - for mocks
@@ -56,12 +65,40 @@ type (
 
 	// Client - custom consul client based on hashicorp client
 	Client struct {
-		config            Config
-		log               *logger.Logger
-		client            ApiConsulClientI
-		sessionID         string
-		hostIP            string
-		timeLastLeaderAck time.Time // время последнего подтверждения лидерства
+		config Config
+		log    *logger.Logger
+		client ApiConsulClientI
+
+		mu                 sync.RWMutex // protect fields below (session is shared between watch and renew goroutines)
+		sessionID          string
+		hostIP             string
+		timeLastLeaderAck  time.Time // время последнего подтверждения лидерства
+		registeredServices []string  // names of services registered by this client
+	}
+
+	// LeaderInfo - info about leader node, stored as value of leader key.
+	LeaderInfo struct {
+		NodeID    string `json:"node_id"`
+		SessionID string `json:"session_id"`
+		HostIP    string `json:"host_ip"`
+		Running   bool   `json:"running"`  // leader has started all master controllers
+		Handover  bool   `json:"handover"` // leader has released key to hand leadership over to successor
+	}
+
+	// NodesState - state of healthy nodes of service.
+	NodesState struct {
+		NodeIDs   []string // ids of app nodes (from service meta)
+		LastIndex uint64   // Consul index, must be passed to next blocking query
+	}
+
+	// LeadershipState - state of leader key in Consul KV.
+	LeadershipState struct {
+		IsLeader     bool       // key is held by session of this client
+		NoLeader     bool       // key is absent or not held by any session
+		Handover     bool       // NoLeader because key was released for handover (not lost because leader crashed)
+		HandoverFrom string     // id of node which has released key for handover
+		Leader       LeaderInfo // info about current leader (empty if NoLeader)
+		LastIndex    uint64     // Consul index, must be passed to next blocking query
 	}
 )
 
@@ -78,6 +115,16 @@ func New(config Config, log *logger.Logger) (*Client, error) {
 		return nil, fmt.Errorf("time.ParseDuration(c.config.SessionTTL): %w", err)
 	}
 
+	c.config.lockDelay, err = time.ParseDuration(c.config.LockDelay)
+	if err != nil {
+		return nil, fmt.Errorf("time.ParseDuration(c.config.LockDelay): %w", err)
+	}
+
+	c.config.waitTimeout, err = time.ParseDuration(c.config.WaitTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("time.ParseDuration(c.config.WaitTimeout): %w", err)
+	}
+
 	client, err := api.NewClient(&api.Config{
 		Address: config.Address,
 	})
@@ -122,10 +169,33 @@ func (c *Client) Register(logger *logger.Logger, services ...RegistrableService)
 				field.String("ServiceName", consulServiceStruct.Name), zap.Error(err))
 			return fmt.Errorf("error registering service '%s' in consul: %w", consulServiceStruct.Name, err)
 		}
+
+		c.mu.Lock()
+		c.registeredServices = append(c.registeredServices, consulServiceStruct.Name)
+		c.mu.Unlock()
 	}
 	return nil
 }
 
+// Peers - return addresses of all healthy nodes of services registered by this client (this node included)
+func (c *Client) Peers() ([]string, error) {
+	c.mu.RLock()
+	services := append([]string(nil), c.registeredServices...)
+	c.mu.RUnlock()
+
+	peers := make([]string, 0, len(services))
+	for _, name := range services {
+		addrs, err := c.Addresses("", name, c.config.Tags)
+		if err != nil {
+			return nil, fmt.Errorf("[Consul] addresses of service %s: %w", name, err)
+		}
+
+		peers = append(peers, addrs...)
+	}
+
+	return peers, nil
+}
+
 // Deregister - deregister service in consul
 func (c *Client) Deregister(logger *logger.Logger, services ...RegistrableService) {
 	err := c.DestroySession()
@@ -143,6 +213,39 @@ func (c *Client) Deregister(logger *logger.Logger, services ...RegistrableServic
 	}
 }
 
+// WatchNodes - blocking query on healthy nodes of service registered by this client (app registers only one service),
+// returns when set of nodes was changed or wait timeout was expired.
+func (c *Client) WatchNodes(ctx context.Context, waitIndex uint64) (NodesState, error) {
+	c.mu.RLock()
+	if len(c.registeredServices) == 0 {
+		c.mu.RUnlock()
+
+		return NodesState{LastIndex: waitIndex}, ErrNoRegisteredServices
+	}
+	serviceName := c.registeredServices[0]
+	c.mu.RUnlock()
+
+	opts := &api.QueryOptions{WaitIndex: waitIndex, WaitTime: c.config.waitTimeout}
+
+	entries, meta, err := c.client.Health().ServiceMultipleTags(serviceName, c.config.Tags, true, opts.WithContext(ctx))
+	if err != nil {
+		return NodesState{LastIndex: waitIndex}, err
+	}
+
+	state := NodesState{NodeIDs: make([]string, 0, len(entries)), LastIndex: meta.LastIndex}
+	if state.LastIndex < waitIndex {
+		state.LastIndex = 0
+	}
+
+	for _, entry := range entries {
+		if nodeID := entry.Service.Meta[MetaNodeID]; nodeID != "" {
+			state.NodeIDs = append(state.NodeIDs, nodeID)
+		}
+	}
+
+	return state, nil
+}
+
 // KVPut - put key value into Consul
 func (c *Client) KVPut(key string, value []byte) error {
 	_, err := c.client.KV().Put(&api.KVPair{
@@ -170,113 +273,230 @@ func (c *Client) KVGet(key string) ([]byte, error) {
 // CreateSession - create session in Consul
 func (c *Client) CreateSession() (string, error) {
 	sessionConf := &api.SessionEntry{
-		TTL:      c.config.SessionTTL,
-		Behavior: "delete",
+		TTL:       c.config.SessionTTL,
+		Behavior:  "delete",
+		LockDelay: c.config.lockDelay, // Consul default is 15s, failover would wait it after crash of leader
 	}
 
 	sessionID, _, err := c.client.Session().Create(sessionConf, nil)
 	if err != nil {
 		return "", err
 	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	c.sessionID = sessionID
 	c.hostIP = helper.GetOutboundIP(c.config.DNS...).String()
 
 	return sessionID, nil
 }
 
+// EnsureSession - create session in Consul only if client has not got any session yet
+func (c *Client) EnsureSession() (string, error) {
+	if sID := c.SessionID(); sID != "" {
+		return sID, nil
+	}
+
+	sID, err := c.CreateSession()
+	if err != nil {
+		return "", err
+	}
+
+	c.log.Info("[Consul] create new session", zap.String("sessionID", sID))
+
+	return sID, nil
+}
+
+// ResetSession - forget current session (e.g. session was invalidated by Consul), next EnsureSession creates new one
+func (c *Client) ResetSession() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sessionID = ""
+}
+
+// SessionID - return current session ID ("" - no session)
+func (c *Client) SessionID() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.sessionID
+}
+
 // AcquireSessionWithKey - acquire session with key in Consul
 func (c *Client) AcquireSessionWithKey(key string) (bool, error) {
+	return c.acquire(key, c.LocalLeaderInfo())
+}
+
+// ConfirmLeadership - mark key held by session of client as "leader is running" (confirmation for previous leader)
+func (c *Client) ConfirmLeadership(key string) (bool, error) {
+	info := c.LocalLeaderInfo()
+	info.Running = true
+
+	return c.acquire(key, info)
+}
+
+func (c *Client) acquire(key string, info LeaderInfo) (bool, error) {
+	value, err := jsoniter.Marshal(info)
+	if err != nil {
+		return false, fmt.Errorf("jsoniter.Marshal(info): %w", err)
+	}
+
 	KVpair := &api.KVPair{
 		Key:     key,
-		Value:   []byte(generateSessionStoreValue(c.sessionID, c.hostIP)),
-		Session: c.sessionID,
+		Value:   value,
+		Session: info.SessionID,
 	}
 
 	acquired, _, err := c.client.KV().Acquire(KVpair, nil)
 	return acquired, err
 }
 
-func generateSessionStoreValue(sessionID string, hostIP string) string {
-	return fmt.Sprintf("%s_%s", sessionID, hostIP)
+// ReleaseSessionWithKey - release key which was acquired by session of client
+func (c *Client) ReleaseSessionWithKey(key string) (bool, error) {
+	KVpair := &api.KVPair{
+		Key:     key,
+		Session: c.SessionID(),
+	}
+
+	released, _, err := c.client.KV().Release(KVpair, nil)
+	return released, err
 }
 
-// IsMySessionIDInKey - check is my session in Consul KV
-func (c *Client) IsMySessionIDInKey(key string) (bool, error) {
-	kvPair, _, err := c.client.KV().Get(key, nil)
+// ReleaseForHandover - release key which was acquired by session of client with "handover" marker in value
+func (c *Client) ReleaseForHandover(key string) (bool, error) {
+	info := c.LocalLeaderInfo()
+	info.Handover = true
+
+	value, err := jsoniter.Marshal(info)
 	if err != nil {
-		return false, err
+		return false, fmt.Errorf("jsoniter.Marshal(info): %w", err)
 	}
-	if kvPair == nil {
-		return false, ErrNilKV
+
+	KVpair := &api.KVPair{
+		Key:     key,
+		Value:   value,
+		Session: info.SessionID,
+	}
+
+	released, _, err := c.client.KV().Release(KVpair, nil)
+	return released, err
+}
+
+// LocalLeaderInfo - return leader info of this node (stored in leader key if this node is a leader)
+func (c *Client) LocalLeaderInfo() LeaderInfo {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return LeaderInfo{
+		NodeID:    c.config.NodeID,
+		SessionID: c.sessionID,
+		HostIP:    c.hostIP,
 	}
-	return string(kvPair.Value) == generateSessionStoreValue(c.sessionID, c.hostIP), nil
+}
+
+// LastLeaderAck - return time of last leadership acknowledgement of this node
+func (c *Client) LastLeaderAck() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return c.timeLastLeaderAck
 }
 
 // DestroySession - destroy session
 func (c *Client) DestroySession() error {
-	_, err := c.client.Session().Destroy(c.sessionID, nil)
+	sessionID := c.SessionID()
+
+	_, err := c.client.Session().Destroy(sessionID, nil)
 	if err != nil {
-		return fmt.Errorf("error cannot delete session %s: %w", c.sessionID, err)
+		return fmt.Errorf("error cannot delete session %s: %w", sessionID, err)
 	}
 
 	return nil
 }
 
-// RenewSession - renew session
-func (c *Client) RenewSession() error {
-	_, _, err := c.client.Session().Renew(c.sessionID, nil)
-	return err
-}
-
-// RenewSessionPeriodic - renew session periodic
+// RenewSessionPeriodic - renew session periodic, blocks until doneChan is closed (session will be destroyed then)
+// or session is invalidated.
 func (c *Client) RenewSessionPeriodic(doneChan <-chan struct{}) error {
-	err := c.client.Session().RenewPeriodic(c.config.SessionTTL, c.sessionID, nil, doneChan)
+	err := c.client.Session().RenewPeriodic(c.config.SessionTTL, c.SessionID(), nil, doneChan)
 	if err != nil {
 		return err
 	}
 	return nil
 }
 
-// CheckLeadership - check leadership (also check not found any leader state)
-func (c *Client) CheckLeadership(oldIsLeader bool, leaderKey string) (isLeader bool, noLeader bool) {
-	isLeader, err := c.IsMySessionIDInKey(leaderKey)
-	if errors.Is(err, ErrNilKV) { // в KV консула нет сведений о ключе вообще
-		c.log.Warn("[Consul] no leader")
+// WatchLeadership - blocking query on leader key, returns when key was changed or wait timeout was expired.
+func (c *Client) WatchLeadership(ctx context.Context, leaderKey string, waitIndex uint64) (LeadershipState, error) {
+	opts := &api.QueryOptions{WaitIndex: waitIndex, WaitTime: c.config.waitTimeout}
+
+	pair, meta, err := c.client.KV().Get(leaderKey, opts.WithContext(ctx))
+	if err != nil {
+		return LeadershipState{LastIndex: waitIndex}, err
+	}
+
+	state := LeadershipState{LastIndex: meta.LastIndex}
+
+	// https://www.consul.io/api-docs/features/blocking#implementation-details (reset index if it goes backwards)
+	if state.LastIndex < waitIndex {
+		state.LastIndex = 0
+	}
 
-		return false, true
-	} else if err != nil { // нет связи, упал консул, другая ошибка
-		c.log.Error("[Consul] error while IsMySessionIDInKey()", zap.Error(err))
+	if pair == nil || pair.Session == "" {
+		state.NoLeader = true
 
-		// Если обрыв связи, недоступен консул и уже прошло больше времени чем живет ключ TTL сессии,
-		// то считаем что мы перестали быть мастером, считаем что наверно кто то другой стал(станет мастером)
-		if time.Since(c.timeLastLeaderAck) > c.config.sessionTTL {
-			return false, false
+		var prev LeaderInfo
+		if pair != nil && jsoniter.Unmarshal(pair.Value, &prev) == nil && prev.Handover {
+			state.Handover, state.HandoverFrom = true, prev.NodeID
 		}
 
-		return oldIsLeader, false
+		return state, nil
 	}
 
-	// happy path, мы узнали есть мы или нет
-	c.timeLastLeaderAck = time.Now().UTC()
-	return isLeader, false
+	if err = jsoniter.Unmarshal(pair.Value, &state.Leader); err != nil {
+		c.log.Warn("[Consul] bad value of leader key", field.String("key", leaderKey), zap.Error(err))
+	}
+	state.Leader.SessionID = pair.Session
+
+	sessionID := c.SessionID()
+	state.IsLeader = sessionID != "" && pair.Session == sessionID
+
+	if state.IsLeader {
+		c.mu.Lock()
+		c.timeLastLeaderAck = time.Now().UTC()
+		c.mu.Unlock()
+	}
+
+	return state, nil
 }
 
-// TryBecomeLeader - try to become leader with leader key
+// IsLeaderAckExpired - true if last leadership acknowledgement was earlier than session TTL ago
+// (e.g. no connection with Consul, so probably somebody else has become a leader).
+func (c *Client) IsLeaderAckExpired() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	return time.Since(c.timeLastLeaderAck) > c.config.sessionTTL
+}
+
+// TryBecomeLeader - try to become leader with leader key (uses long-lived session of client)
 func (c *Client) TryBecomeLeader(leaderKey string) (bool, error) {
-	sID, err := c.CreateSession()
-	if err != nil {
-		c.log.Error("[Consul] CreateSession()", zap.Error(err))
+	if _, err := c.EnsureSession(); err != nil {
+		c.log.Error("[Consul] EnsureSession()", zap.Error(err))
 		return false, fmt.Errorf("[Consul] create session problem: %w", err)
 	}
-	c.log.Info("[Consul] create new session", zap.String("sessionID", sID))
 
 	res, err := c.AcquireSessionWithKey(leaderKey)
-	if !res {
-		return false, nil
-	}
 	if err != nil {
 		return false, fmt.Errorf("[Consul] acquire session with key problem: %w", err)
 	}
+	if !res {
+		return false, nil
+	}
+
+	c.mu.Lock()
+	c.timeLastLeaderAck = time.Now().UTC()
+	c.mu.Unlock()
 
 	c.log.Info("[Consul] Successfully AcquireSessionWithKey")
 	return true, nil
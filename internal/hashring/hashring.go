@@ -0,0 +1,67 @@
+// Package hashring - consistent hashing ring with virtual nodes.
+package hashring
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// DefaultReplicas - default cnt of virtual nodes per node.
+const DefaultReplicas = 64
+
+// Ring - consistent hashing ring (not thread safe, build new ring on nodes change).
+type Ring struct {
+	replicas int
+	hashes   []uint32          // sorted hashes of virtual nodes
+	nodes    map[uint32]string // hash of virtual node -> node
+}
+
+// New - constructor of Ring.
+func New(replicas int, nodes ...string) *Ring {
+	if replicas <= 0 {
+		replicas = DefaultReplicas
+	}
+
+	r := &Ring{
+		replicas: replicas,
+		hashes:   make([]uint32, 0, replicas*len(nodes)),
+		nodes:    make(map[uint32]string, replicas*len(nodes)),
+	}
+
+	for _, node := range nodes {
+		for i := 0; i < replicas; i++ {
+			h := hash(strconv.Itoa(i) + "_" + node)
+			if _, found := r.nodes[h]; found {
+				continue // collision, first node wins
+			}
+
+			r.nodes[h] = node
+			r.hashes = append(r.hashes, h)
+		}
+	}
+
+	sort.Slice(r.hashes, func(i, j int) bool { return r.hashes[i] < r.hashes[j] })
+
+	return r
+}
+
+// Get - return node which owns key ("" - ring is empty).
+func (r *Ring) Get(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+
+	h := hash(key)
+
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= h })
+	if i == len(r.hashes) {
+		i = 0
+	}
+
+	return r.nodes[r.hashes[i]]
+}
+
+func hash(key string) uint32 {
+	return crc32.ChecksumIEEE([]byte(key))
+}
@@ -0,0 +1,39 @@
+package hashring
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRing_Empty(t *testing.T) {
+	assert.Equal(t, "", New(0).Get("BTCUSD"))
+}
+
+func TestRing_Get(t *testing.T) {
+	r := New(DefaultReplicas, "node1", "node2", "node3")
+
+	owners := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		key := "key" + strconv.Itoa(i)
+		owner := r.Get(key)
+
+		assert.Equal(t, owner, r.Get(key)) // stable
+		owners[owner]++
+	}
+
+	assert.Len(t, owners, 3)
+}
+
+func TestRing_RebalanceMovesOnlyKeysOfLeftNode(t *testing.T) {
+	before := New(DefaultReplicas, "node1", "node2", "node3")
+	after := New(DefaultReplicas, "node1", "node2")
+
+	for i := 0; i < 1000; i++ {
+		key := "key" + strconv.Itoa(i)
+		if owner := before.Get(key); owner != "node3" {
+			assert.Equal(t, owner, after.Get(key))
+		}
+	}
+}
@@ -11,3 +11,23 @@ func Test_GetOutboundIP(t *testing.T) {
 	assert.Equal(t, net.IP{0x31, 0x32, 0x37, 0x2e, 0x30, 0x2e, 0x30, 0x2e, 0x31}, GetOutboundIP())
 	assert.NotNil(t, GetOutboundIP("8.8.8.8", "1.1.1.1", "127.0.0.1"))
 }
+
+func TestIsPublicIP(t *testing.T) {
+	for _, ip := range []string{"8.8.8.8", "2a00:1450:4001:82a::200e"} {
+		assert.True(t, IsPublicIP(net.ParseIP(ip)), ip)
+	}
+
+	for _, ip := range []string{
+		"127.0.0.1", "::1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254", "0.0.0.0", "fe80::1",
+		"fd00::1", "::ffff:127.0.0.1", "224.0.0.1",
+	} {
+		assert.False(t, IsPublicIP(net.ParseIP(ip)), ip)
+	}
+}
+
+func TestPublicIPDialControl(t *testing.T) {
+	assert.NoError(t, PublicIPDialControl("tcp4", "8.8.8.8:443", nil))
+	assert.ErrorIs(t, PublicIPDialControl("tcp4", "169.254.169.254:80", nil), ErrNotPublicIP)
+	assert.ErrorIs(t, PublicIPDialControl("tcp6", "[::1]:8500", nil), ErrNotPublicIP)
+	assert.Error(t, PublicIPDialControl("tcp4", "bad", nil))
+}
@@ -1,6 +1,14 @@
 package helper
 
-import "net"
+import (
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// ErrNotPublicIP - ip address is loopback, private, link-local or other internal (not public) address.
+var ErrNotPublicIP = errors.New("not public ip address")
 
 // GetOutboundIP - Get preferred outbound ip of this machine.
 func GetOutboundIP(dnss ...string) net.IP {
@@ -24,3 +32,24 @@ func GetOutboundIP(dnss ...string) net.IP {
 
 	return defaultAddr
 }
+
+// IsPublicIP - is ip public unicast address (not loopback, private, link-local, unspecified or multicast one).
+func IsPublicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
+
+// PublicIPDialControl - control func of net.Dialer, refuses connections to not public ip addresses. Address is
+// checked after resolving of host, so host resolved to internal address later (DNS rebinding) is refused too.
+func PublicIPDialControl(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return fmt.Errorf("bad address %q: %w", address, err)
+	}
+
+	if ip := net.ParseIP(host); ip == nil || !IsPublicIP(ip) {
+		return fmt.Errorf("%w: %s", ErrNotPublicIP, host)
+	}
+
+	return nil
+}
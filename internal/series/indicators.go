@@ -0,0 +1,265 @@
+package series
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Kinds of indicators.
+const (
+	SMA    = "sma"    // simple moving average
+	EMA    = "ema"    // exponential moving average (seeded by SMA of first period values)
+	RSI    = "rsi"    // relative strength index (Wilder's smoothing)
+	BBands = "bbands" // bollinger bands: SMA -/+ 2 standard deviations
+)
+
+const (
+	maxIndicators      = 8
+	maxIndicatorPeriod = 1000
+
+	bbandsK = 2
+
+	// warmUpPeriods - EMA and RSI depend on all previous values, when they are computed over points before page,
+	// so many periods are enough (weight of older values is negligible)
+	warmUpPeriods = 10
+)
+
+// ErrBadIndicator - indicator is not in kind:period format, kind is unknown or period is out of range.
+var ErrBadIndicator = errors.New("bad indicator")
+
+type (
+	// Indicator - technical indicator of series.
+	Indicator struct {
+		Kind   string
+		Period int
+	}
+
+	// Line - values of indicator (or one of its bands) aligned with points of series,
+	// nil - no value (warm-up period of indicator is not passed yet or point is null).
+	Line struct {
+		Name   string
+		Values []*float64
+	}
+)
+
+// ParseIndicators - parse indicators from "kind:period,kind:period" string.
+func ParseIndicators(raw string) ([]Indicator, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	parts := strings.Split(raw, ",")
+	if len(parts) > maxIndicators {
+		return nil, fmt.Errorf("%w: too many indicators (max %d)", ErrBadIndicator, maxIndicators)
+	}
+
+	r := make([]Indicator, 0, len(parts))
+
+	for _, p := range parts {
+		kind, period, _ := strings.Cut(strings.TrimSpace(p), ":")
+
+		n, err := strconv.Atoi(period)
+		if err != nil || n < 1 || n > maxIndicatorPeriod {
+			return nil, fmt.Errorf("%w: %q (expected kind:period, period in [1, %d])",
+				ErrBadIndicator, p, maxIndicatorPeriod)
+		}
+
+		switch kind = strings.ToLower(kind); kind {
+		case SMA, EMA, RSI, BBands:
+		default:
+			return nil, fmt.Errorf("%w: unknown kind %q", ErrBadIndicator, kind)
+		}
+
+		r = append(r, Indicator{Kind: kind, Period: n})
+	}
+
+	return r, nil
+}
+
+// String - kind:period.
+func (ind Indicator) String() string {
+	return ind.Kind + ":" + strconv.Itoa(ind.Period)
+}
+
+// Lookback - cnt of points before page needed to compute indicator on page: exactly period-1 for SMA and bbands,
+// warm-up periods for EMA and RSI (approximately, they depend on all previous values).
+func (ind Indicator) Lookback() int {
+	switch ind.Kind {
+	case EMA, RSI:
+		return warmUpPeriods * ind.Period
+	default:
+		return ind.Period - 1
+	}
+}
+
+// Lookback - max lookback of indicators.
+func Lookback(indicators []Indicator) int {
+	r := 0
+
+	for _, ind := range indicators {
+		if n := ind.Lookback(); n > r {
+			r = n
+		}
+	}
+
+	return r
+}
+
+// Compute - lines of indicator over points (bbands has middle, upper and lower lines). Null points are skipped:
+// they have no value and do not move indicator. Values are nil until warm-up period of indicator is passed
+// (period non-null points, period+1 for RSI), so points before window must be passed to get them from its start.
+func (ind Indicator) Compute(points []Point) []Line {
+	switch ind.Kind {
+	case SMA:
+		return []Line{{Name: ind.String(), Values: sma(points, ind.Period)}}
+	case EMA:
+		return []Line{{Name: ind.String(), Values: ema(points, ind.Period)}}
+	case RSI:
+		return []Line{{Name: ind.String(), Values: rsi(points, ind.Period)}}
+	case BBands:
+		middle, upper, lower := bbands(points, ind.Period)
+
+		return []Line{
+			{Name: ind.String() + ":middle", Values: middle},
+			{Name: ind.String() + ":upper", Values: upper},
+			{Name: ind.String() + ":lower", Values: lower},
+		}
+	}
+
+	return nil
+}
+
+func sma(points []Point, n int) []*float64 {
+	r := make([]*float64, len(points))
+
+	window := make([]float64, 0, len(points))
+	sum := 0.
+
+	for i, p := range points {
+		if p.Null {
+			continue
+		}
+
+		window = append(window, p.Value)
+		sum += p.Value
+
+		if len(window) > n {
+			sum -= window[len(window)-n-1]
+		}
+
+		if len(window) >= n {
+			r[i] = value(sum / float64(n))
+		}
+	}
+
+	return r
+}
+
+func ema(points []Point, n int) []*float64 {
+	r := make([]*float64, len(points))
+
+	alpha := 2 / float64(n+1)
+	cnt, avg := 0, 0.
+
+	for i, p := range points {
+		if p.Null {
+			continue
+		}
+
+		cnt++
+
+		switch {
+		case cnt < n:
+			avg += p.Value / float64(n)
+
+			continue
+		case cnt == n:
+			avg += p.Value / float64(n)
+		default:
+			avg += alpha * (p.Value - avg)
+		}
+
+		r[i] = value(avg)
+	}
+
+	return r
+}
+
+func rsi(points []Point, n int) []*float64 {
+	r := make([]*float64, len(points))
+
+	cnt := -1 // cnt of changes, first point has no change
+	prev, gain, loss := 0., 0., 0.
+
+	for i, p := range points {
+		if p.Null {
+			continue
+		}
+
+		change := p.Value - prev
+		prev = p.Value
+
+		if cnt++; cnt == 0 {
+			continue
+		}
+
+		g, l := math.Max(change, 0), math.Max(-change, 0)
+
+		if cnt <= n {
+			gain, loss = gain+g/float64(n), loss+l/float64(n)
+			if cnt < n {
+				continue
+			}
+		} else {
+			gain = (gain*float64(n-1) + g) / float64(n)
+			loss = (loss*float64(n-1) + l) / float64(n)
+		}
+
+		switch {
+		case loss == 0 && gain == 0:
+			r[i] = value(50) // flat
+		case loss == 0:
+			r[i] = value(100)
+		default:
+			r[i] = value(100 - 100/(1+gain/loss))
+		}
+	}
+
+	return r
+}
+
+func bbands(points []Point, n int) (middle, upper, lower []*float64) {
+	middle = sma(points, n)
+	upper, lower = make([]*float64, len(points)), make([]*float64, len(points))
+
+	window := make([]float64, 0, len(points))
+
+	for i, p := range points {
+		if p.Null {
+			continue
+		}
+
+		window = append(window, p.Value)
+
+		if middle[i] == nil {
+			continue
+		}
+
+		variance := 0.
+		for _, v := range window[len(window)-n:] {
+			variance += (v - *middle[i]) * (v - *middle[i])
+		}
+
+		sd := math.Sqrt(variance / float64(n))
+		upper[i], lower[i] = value(*middle[i]+bbandsK*sd), value(*middle[i]-bbandsK*sd)
+	}
+
+	return middle, upper, lower
+}
+
+func value(v float64) *float64 {
+	return &v
+}
@@ -0,0 +1,84 @@
+package series
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseIndicators(t *testing.T) {
+	inds, err := ParseIndicators("sma:20, EMA:50,rsi:14,bbands:20")
+	require.NoError(t, err)
+	assert.Equal(t, []Indicator{{SMA, 20}, {EMA, 50}, {RSI, 14}, {BBands, 20}}, inds)
+
+	inds, err = ParseIndicators("")
+	require.NoError(t, err)
+	assert.Empty(t, inds)
+
+	for _, raw := range []string{"sma", "sma:0", "sma:1001", "macd:12", "sma:20,", "sma:x"} {
+		_, err = ParseIndicators(raw)
+		assert.ErrorIs(t, err, ErrBadIndicator, raw)
+	}
+}
+
+func TestIndicator_Compute(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := make([]Point, 0, 6)
+
+	for i, v := range []float64{1, 2, 3, 0, 4, 5} {
+		p := Point{Time: start.Add(time.Duration(i) * time.Second), Value: v}
+		p.Null = i == 3
+
+		points = append(points, p)
+	}
+
+	values := func(lines []Line) [][]any {
+		r := make([][]any, 0, len(lines))
+
+		for _, l := range lines {
+			vs := make([]any, 0, len(l.Values))
+			for _, v := range l.Values {
+				if v == nil {
+					vs = append(vs, nil)
+
+					continue
+				}
+
+				vs = append(vs, *v)
+			}
+
+			r = append(r, vs)
+		}
+
+		return r
+	}
+
+	assert.Equal(t, [][]any{{nil, nil, 2., nil, 3., 4.}}, values(Indicator{SMA, 3}.Compute(points)))
+	assert.Equal(t, [][]any{{nil, nil, 2., nil, 3., 4.}}, values(Indicator{EMA, 3}.Compute(points)))
+	assert.Equal(t, [][]any{{nil, nil, 100., nil, 100., 100.}}, values(Indicator{RSI, 2}.Compute(points)))
+
+	bb := Indicator{BBands, 2}.Compute(points)
+	require.Len(t, bb, 3)
+	assert.Equal(t, []string{"bbands:2:middle", "bbands:2:upper", "bbands:2:lower"},
+		[]string{bb[0].Name, bb[1].Name, bb[2].Name})
+	assert.Equal(t, [][]any{
+		{nil, 1.5, 2.5, nil, 3.5, 4.5},
+		{nil, 2.5, 3.5, nil, 4.5, 5.5},
+		{nil, 0.5, 1.5, nil, 2.5, 3.5},
+	}, values(bb))
+
+	rsi := values(Indicator{RSI, 2}.Compute([]Point{{Value: 1}, {Value: 3}, {Value: 2}, {Value: 2}}))
+	assert.Nil(t, rsi[0][1])
+	assert.InDelta(t, 66.667, rsi[0][2], 1e-3) // avg gain 1, avg loss 0.5
+	assert.InDelta(t, 66.667, rsi[0][3], 1e-3) // avg gain 0.5, avg loss 0.25
+}
+
+func TestLookback(t *testing.T) {
+	assert.Equal(t, 19, Indicator{SMA, 20}.Lookback())
+	assert.Equal(t, 19, Indicator{BBands, 20}.Lookback())
+	assert.Equal(t, 140, Indicator{RSI, 14}.Lookback())
+	assert.Equal(t, 140, Lookback([]Indicator{{SMA, 20}, {RSI, 14}, {EMA, 5}}))
+	assert.Equal(t, 0, Lookback(nil))
+}
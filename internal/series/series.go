@@ -0,0 +1,121 @@
+// Package series - time series of prices aligned to grid of frequency steps: gap filling and interpolation.
+package series
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Fill modes of gaps (steps without observed point).
+const (
+	FillNone     Mode = "none"     // gaps are kept, only observed points
+	FillPrevious Mode = "previous" // last observed value carried forward (LOCF)
+	FillLinear   Mode = "linear"   // linear interpolation between observed neighbours
+	FillNull     Mode = "null"     // null placeholders
+)
+
+// ErrBadMode - unknown fill mode.
+var ErrBadMode = errors.New("bad fill mode")
+
+type (
+	// Mode - fill mode of gaps.
+	Mode = string
+
+	// Point - point of series.
+	Point struct {
+		Time     time.Time
+		Value    float64
+		Null     bool // point has no value (null placeholder or gap which can't be filled)
+		Observed bool // false - point is synthesized by filling
+	}
+)
+
+// Fill - one point per step: observed point of step or synthesized by mode. Steps and observed points are sorted
+// by time, time of observed point is time of its step (observed points not on steps are ignored).
+// Prev and next - nearest observed points before first and after last step (nil if there are no ones), they are
+// used to fill gaps on edges of steps (e.g. on page boundaries).
+func Fill(steps []time.Time, observed []Point, prev, next *Point, mode Mode) ([]Point, error) {
+	switch mode {
+	case FillNone:
+		return observed, nil
+	case FillPrevious, FillLinear, FillNull:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrBadMode, mode)
+	}
+
+	r := make([]Point, 0, len(steps))
+
+	j := 0
+	for _, step := range steps {
+		for j < len(observed) && observed[j].Time.Before(step) {
+			j++
+		}
+
+		if j < len(observed) && observed[j].Time.Equal(step) {
+			p := observed[j]
+			p.Observed = true
+			r = append(r, p)
+
+			continue
+		}
+
+		r = append(r, Point{Time: step, Null: true})
+	}
+
+	switch mode {
+	case FillPrevious:
+		fillPrevious(r, prev)
+	case FillLinear:
+		fillLinear(r, prev, next)
+	}
+
+	return r, nil
+}
+
+func fillPrevious(points []Point, prev *Point) {
+	for i := range points {
+		if points[i].Observed {
+			prev = &points[i]
+
+			continue
+		}
+
+		if prev != nil {
+			points[i].Value, points[i].Null = prev.Value, false
+		}
+	}
+}
+
+func fillLinear(points []Point, prev, next *Point) {
+	for i := 0; i < len(points); {
+		if points[i].Observed {
+			prev = &points[i]
+			i++
+
+			continue
+		}
+
+		// gap [i, k)
+		k := i
+		for k < len(points) && !points[k].Observed {
+			k++
+		}
+
+		right := next
+		if k < len(points) {
+			right = &points[k]
+		}
+
+		if prev != nil && right != nil {
+			span := float64(right.Time.Sub(prev.Time))
+
+			for g := i; g < k; g++ {
+				w := float64(points[g].Time.Sub(prev.Time)) / span
+				points[g].Value, points[g].Null = prev.Value+(right.Value-prev.Value)*w, false
+			}
+		}
+
+		i = k
+	}
+}
@@ -0,0 +1,62 @@
+package series
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFill(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(sec int) time.Time { return start.Add(time.Duration(sec) * time.Second) }
+
+	steps := []time.Time{at(0), at(10), at(20), at(30), at(40)}
+	observed := []Point{{Time: at(10), Value: 10}, {Time: at(15), Value: 100}, {Time: at(40), Value: 40}}
+
+	r, err := Fill(steps, observed, nil, nil, FillNull)
+	require.NoError(t, err)
+	assert.Equal(t, []Point{
+		{Time: at(0), Null: true},
+		{Time: at(10), Value: 10, Observed: true},
+		{Time: at(20), Null: true},
+		{Time: at(30), Null: true},
+		{Time: at(40), Value: 40, Observed: true},
+	}, r)
+
+	r, err = Fill(steps, observed, nil, nil, FillPrevious)
+	require.NoError(t, err)
+	assert.Equal(t, []Point{
+		{Time: at(0), Null: true},
+		{Time: at(10), Value: 10, Observed: true},
+		{Time: at(20), Value: 10},
+		{Time: at(30), Value: 10},
+		{Time: at(40), Value: 40, Observed: true},
+	}, r)
+
+	r, err = Fill(steps, observed, &Point{Time: at(-10), Value: 0}, nil, FillLinear)
+	require.NoError(t, err)
+	assert.Equal(t, []Point{
+		{Time: at(0), Value: 5},
+		{Time: at(10), Value: 10, Observed: true},
+		{Time: at(20), Value: 20},
+		{Time: at(30), Value: 30},
+		{Time: at(40), Value: 40, Observed: true},
+	}, r)
+
+	r, err = Fill(steps[:3], observed[:1], nil, &Point{Time: at(30), Value: 70}, FillLinear) // next is on next page
+	require.NoError(t, err)
+	assert.Equal(t, []Point{
+		{Time: at(0), Null: true},
+		{Time: at(10), Value: 10, Observed: true},
+		{Time: at(20), Value: 40},
+	}, r)
+
+	r, err = Fill(steps, observed, nil, nil, FillNone)
+	require.NoError(t, err)
+	assert.Equal(t, observed, r)
+
+	_, err = Fill(steps, observed, nil, nil, "spline")
+	assert.ErrorIs(t, err, ErrBadMode)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
 	"net/http"
 	"strings"
 	"time"
@@ -12,34 +13,89 @@ import (
 
 	"github.com/imperiuse/price_monitor/internal/helper"
 	"github.com/imperiuse/price_monitor/internal/logger/field"
+	"github.com/imperiuse/price_monitor/internal/series"
 	mw "github.com/imperiuse/price_monitor/internal/servers/http/middlerware"
+	"github.com/imperiuse/price_monitor/internal/services/controllers/general/monitor"
 	"github.com/imperiuse/price_monitor/internal/services/storage"
 	"github.com/imperiuse/price_monitor/internal/services/storage/model"
 )
 
-const defaultLimit = 10000
+const (
+	defaultLimit = 10000
+
+	// TODO need clarify this, I add my constraints instead
+	maxMonitoringPeriod    = 24 * time.Hour
+	minMonitoringFrequency = time.Second
+
+	// max wait of monitoring finish (long polling), it's also limited by write timeout of server minus reserve
+	maxMonitoringWait = time.Minute
+	waitWriteReserve  = 5 * time.Second
+)
+
+var errBadMonitoringParams = errors.New("period to much or freq too low")
 
 type (
 	FormGetMonitoring struct {
 		ID     int64  `uri:"id" binding:"required,min=1,max=9223372036854775807"`
 		Delete bool   `form:"delete"  binding:"omitempty"`
-		Cursor uint64 `form:"cursor"  binding:"omitempty,min=0,max=18446744073709551615"`
+		Cursor uint64 `form:"cursor"  binding:"omitempty,min=0,max=18446744073709551615"` // NextCursor of previous page
 		Limit  uint64 `form:"limit"  binding:"omitempty,min=1,max=10000"`
+		Agg    string `form:"agg"  binding:"omitempty,oneof=first last avg closest"`     // sample of every frequency slot
+		Fill   string `form:"fill"  binding:"omitempty,oneof=none previous linear null"` // filling of slots without prices
+
+		Indicators string `form:"indicators"  binding:"omitempty,max=200"` // sma:20,ema:50,rsi:14,bbands:20
+
+		Wait string `form:"wait"  binding:"omitempty,min=2,max=10"` // 30s, wait finish of monitoring at most
 	}
 
-	FormPostMonitoring struct {
-		// TODO ALSO CAN BE LIKE HERE (I decide to go straightforward, simple and utility)
-		//FromTime  time.Time `form:"from" binding:"required" time_format:"2006-01-02T15:04:05" time_utc:"0"` // time.RFC3339
-		//ToTime    time.Time `form:"to" binding:"required" time_format:"2006-01-02T15:04:05" time_utc:"0"`   // time.RFC3339
+	FormMonitoringID struct {
+		ID int64 `uri:"id" binding:"required,min=1,max=9223372036854775807"`
+	}
 
+	FormPostMonitoring struct {
 		Period    string `form:"period" binding:"required,min=2,max=10"` // 30s, 1m, 1h
 		Frequency string `form:"freq" binding:"required,min=2,max=10"`   // 1s, 5s, 1m
 		Currency  string `form:"cur" binding:"required,min=3,max=10"`    // btcusd
+
+		// optional scheduled start (monitoring starts now by default), only one of them can be set
+		StartAt time.Time `form:"start_at" binding:"omitempty" time_format:"2006-01-02T15:04:05Z07:00"` // RFC3339
+		StartIn string    `form:"start_in" binding:"omitempty,min=2,max=10"`                            // 10m, 1h
+
+		Labels []string `form:"label" binding:"omitempty"` // key:value
+
+		// result is posted there when monitoring is finished
+		CallbackURL string `form:"callback_url" binding:"omitempty,url,max=2048"`
+	}
+
+	FormPatchMonitoring struct {
+		Extend    string    `form:"extend" binding:"omitempty,min=2,max=10"`                              // 10m, 1h
+		Frequency string    `form:"freq" binding:"omitempty,min=2,max=10"`                                // 1s, 5s, 1m
+		StartAt   time.Time `form:"start_at" binding:"omitempty" time_format:"2006-01-02T15:04:05Z07:00"` // RFC3339
+	}
+
+	// slotsCounter - count slots of frequency in [from, to) with at least one collected price.
+	slotsCounter func(from, to time.Time, freq time.Duration) (int, error)
+
+	// freqSegment - part of monitoring window (from time till next segment) with its own frequency.
+	freqSegment struct {
+		from time.Time
+		freq time.Duration
+	}
+
+	// ResponseCoverage - how much of monitoring window is covered by collected prices.
+	ResponseCoverage struct {
+		Expected  int     `json:"expected"`  // cnt of frequency slots in window
+		Collected int     `json:"collected"` // cnt of slots with at least one collected price
+		Ratio     float64 `json:"ratio"`     // collected / expected
 	}
 
 	ResponsePrice struct {
-		Time  time.Time `json:"time"`
-		Price float64   `json:"price"`
+		Time     time.Time `json:"time"`
+		Price    *float64  `json:"price"`    // null - gap filled by null placeholder
+		Observed bool      `json:"observed"` // false - price is synthesized by filling of gap
+
+		// values of requested indicators by name (like sma:20, bbands:20:upper), null - warm-up or null price
+		Indicators map[string]*float64 `json:"indicators,omitempty"`
 	}
 )
 
@@ -64,11 +120,88 @@ func (s *Server) Health(c *gin.Context) {
 // @Accept  json
 // @Produce  json
 // @Success 200
+// @Failure 503 {object} util.HTTPErrorResponse
 // @Router /ready [get]
 func (s *Server) Readiness(c *gin.Context) {
+	if err := s.cluster.Ready(); err != nil {
+		s.SendErrorJSON(c, http.StatusServiceUnavailable, "node is not ready", err)
+
+		return
+	}
+
 	c.String(http.StatusOK, `{"NodeID": %s}`, s.config.NodeID)
 }
 
+// GetCluster godoc
+// @Summary Get cluster status
+// @Description get current leader, healthy nodes, last leadership ack of this node and states of its controllers
+// @Id GetCluster
+// @Tags Server API
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} monitor.ClusterStatus
+// @Failure 500 {object} util.HTTPErrorResponse
+// @Router /api/v1/cluster [get]
+func (s *Server) GetCluster(c *gin.Context) {
+	ctx, cf := context.WithCancel(
+		helper.NewContextWithUUID(c.Copy().Request.Context(), c.GetString(mw.XRequestID)),
+	)
+	defer cf()
+
+	status, err := s.cluster.ClusterStatus(ctx)
+	if err != nil {
+		s.log.Error("can not get cluster status", field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not get cluster status", err)
+
+		return
+	}
+
+	s.SendJSON(c, http.StatusOK, "Cluster status",
+		gin.H{
+			"NodeID":        status.NodeID,
+			"IsLeader":      status.IsLeader,
+			"Leader":        status.Leader,
+			"Nodes":         status.Nodes,
+			"LastLeaderAck": status.LastLeaderAck,
+			"Controllers":   status.Controllers,
+		})
+}
+
+// PostLeadershipRelease godoc
+// @Summary Release leadership
+// @Description gracefully hand leadership of this node over to other node
+// @Id PostLeadershipRelease
+// @Tags Server Admin
+// @Accept  json
+// @Produce  json
+// @Param Authorization header string true "Bearer <admin token>"
+// @Success 200 {object} util.HTTPGoodResponse
+// @Failure 401 {object} util.HTTPErrorResponse
+// @Failure 409 {object} util.HTTPErrorResponse
+// @Failure 500 {object} util.HTTPErrorResponse
+// @Router /admin/leadership/release [post]
+func (s *Server) PostLeadershipRelease(c *gin.Context) {
+	ctx, cf := context.WithCancel(
+		helper.NewContextWithUUID(c.Copy().Request.Context(), c.GetString(mw.XRequestID)),
+	)
+	defer cf()
+
+	if err := s.cluster.Handover(ctx); err != nil {
+		if errors.Is(err, monitor.ErrNotMaster) {
+			s.SendErrorJSON(c, http.StatusConflict, "node is not a leader", err)
+
+			return
+		}
+
+		s.log.Error("can not release leadership", field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not release leadership", err)
+
+		return
+	}
+
+	s.SendJSON(c, http.StatusOK, "Leadership has been released", nil)
+}
+
 // GetMonitoring godoc
 // @Summary Get Monitoring data
 // @Description get monitoring data
@@ -76,12 +209,17 @@ func (s *Server) Readiness(c *gin.Context) {
 // @Tags Server API
 // @Param id path int true "id of road controller"
 // @Param delete query bool false "delete monitoring after"
-// @Param cursor query int false "cursor for cursor pagination"
-// @Param limit query int false "limit for limit pagination"// todo https://uxdesign.cc/why-facebook-says-cursor-pagination-is-the-greatest-d6b98d86b6c0
+// @Param cursor query int false "NextCursor from previous page of prices"
+// @Param limit query int false "limit of prices in page"
+// @Param agg query string false "sample of every frequency slot: first (default), last, avg, closest (to slot start)"
+// @Param fill query string false "filling of slots without prices: none (default), previous, linear, null"
+// @Param indicators query string false "indicators of prices like sma:20,ema:50,rsi:14,bbands:20"
+// @Param wait query string false "wait finish of monitoring at most like 30s (long polling) instead of 202 at once"
 // @Accept  json
 // @Produce  json
 // @Success 200
-// @Success 202 {object} util.HTTPErrorResponse // todo https://softwareengineering.stackexchange.com/questions/316208/http-status-code-for-still-processing
+// @Success 202 {object} util.HTTPGoodResponse // todo https://softwareengineering.stackexchange.com/questions/316208/http-status-code-for-still-processing
+// @Success 410 {object} util.HTTPGoodResponse
 // @Failure 400 {object} util.HTTPErrorResponse
 // @Failure 500 {object} util.HTTPErrorResponse
 // @Router /api/v1/monitoring/{id} [get]
@@ -110,8 +248,35 @@ func (s *Server) GetMonitoring(c *gin.Context) { // TODO  toooo-looong func need
 		f.Limit = defaultLimit
 	}
 
-	var m model.Monitoring
-	err := s.storage.Connector().Repo(m).Get(ctx, f.ID, &m)
+	if f.Agg == "" {
+		f.Agg = storage.AggFirst
+	}
+
+	if f.Fill == "" {
+		f.Fill = series.FillNone
+	}
+
+	indicators, err := series.ParseIndicators(f.Indicators)
+	if err != nil {
+		s.SendErrorJSON(c, http.StatusBadRequest, "bad value for indicators", err)
+
+		return
+	}
+
+	var wait time.Duration
+	if f.Wait != "" {
+		if wait, err = time.ParseDuration(f.Wait); err != nil || wait < 0 {
+			s.SendErrorJSON(c, http.StatusBadRequest, "bad value for wait", err)
+
+			return
+		}
+
+		if wait > s.maxWait {
+			wait = s.maxWait
+		}
+	}
+
+	m, err := s.waitMonitoring(ctx, f.ID, wait)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			s.log.Debug("not found monitoring obj with id", field.ID(f.ID))
@@ -126,9 +291,26 @@ func (s *Server) GetMonitoring(c *gin.Context) { // TODO  toooo-looong func need
 		return
 	}
 
-	if time.Now().UTC().Before(m.ExpiredAt) {
+	if m.Status == model.StatusCancelled {
+		s.log.Debug("monitoring was cancelled", field.Any("form", f), field.ID(f.ID))
+		s.SendJSON(c, http.StatusGone, "monitoring was cancelled, result is discarded",
+			gin.H{
+				"MonitoringID":    f.ID,
+				"Status":          m.Status,
+				"StatusChangedAt": m.StatusChangedAt,
+			})
+
+		return
+	}
+
+	if !model.IsFinalStatus(m.Status) {
 		s.log.Debug("monitoring has not finished yet", field.Any("form", f), field.ID(f.ID))
-		s.SendErrorJSON(c, http.StatusAccepted, "monitoring has not finished yet", nil)
+		s.SendJSON(c, http.StatusAccepted, "monitoring has not finished yet",
+			gin.H{
+				"MonitoringID":    f.ID,
+				"Status":          m.Status,
+				"StatusChangedAt": m.StatusChangedAt,
+			})
 
 		return
 	}
@@ -141,7 +323,19 @@ func (s *Server) GetMonitoring(c *gin.Context) { // TODO  toooo-looong func need
 		return
 	}
 
-	freq, err := time.ParseDuration(m.Frequency)
+	changes, err := storage.MonitoringChanges(ctx, s.storage, m.ID)
+	if err != nil {
+		s.log.Error("can not get changes of monitoring", field.ID(f.ID), field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not get changes of monitoring", err)
+
+		return
+	}
+
+	if len(changes) == 0 { // monitoring was created before changes history
+		changes = append(changes, model.MonitoringChange{Frequency: m.Frequency, EffectiveFrom: m.StartedAt})
+	}
+
+	segments, err := buildFreqSegments(changes, m.StartedAt)
 	if err != nil {
 		s.log.Error("bad value for frequency from db", field.ID(f.ID), field.Any("m", m), field.Error(err))
 		s.SendErrorJSON(c, http.StatusInternalServerError, "bad value for frequency from db ", err)
@@ -149,17 +343,32 @@ func (s *Server) GetMonitoring(c *gin.Context) { // TODO  toooo-looong func need
 		return
 	}
 
-	prices := make([]model.Price, 0, f.Limit)
-	// TODO Pagination (cursor, page, or other)
-	err = s.storage.Connector().RepoByName(model.PriceTableNameGetterFunc(curCode)).
-		Select(ctx,
-			storage.
-				Select("time, price").
-				Where("time BETWEEN ? AND ?", m.StartedAt, m.ExpiredAt).
-				OrderBy("time"),
-			&prices,
-		)
+	coverage, err := calcCoverage(segments, m.ExpiredAt, s.slotsCollector(ctx, curCode))
+	if err != nil {
+		s.log.Error("can not calc coverage of monitoring", field.ID(f.ID), field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not calc coverage of monitoring", err)
+
+		return
+	}
+
+	summary, err := storage.SummarizePrices(ctx, s.storage, curCode, m.StartedAt, m.ExpiredAt)
+	if err != nil {
+		s.log.Error("can not summarize prices of monitoring", field.ID(f.ID), field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not summarize prices of monitoring", err)
+
+		return
+	}
+
+	summary.Expected = coverage.Expected
+
+	var cursor time.Time // keyset on bucket of last price of previous page
+	if f.Cursor != 0 {
+		cursor = time.Unix(0, int64(f.Cursor)).UTC()
+	}
+
+	pSegments := priceSegments(segments, m.ExpiredAt)
 
+	points, next, err := s.pricesPage(ctx, curCode, pSegments, f.Agg, f.Fill, f.Limit, cursor)
 	if err != nil {
 		s.log.Error("can not get prices data for monitoring",
 			field.ID(f.ID), field.Any("form", f), field.Error(err))
@@ -168,14 +377,22 @@ func (s *Server) GetMonitoring(c *gin.Context) { // TODO  toooo-looong func need
 		return
 	}
 
-	// Yes I understand that this solution not perfect, and probably really not good, but without any addional info
-	//for test task. I think this variant of architect is convenient now, but of course we can remove data consumption
-	//from db and in the app, but we need more complicated architect
-	// todo probably should work thi approach https://stackoverflow.com/questions/39334814/how-to-extract-hour-from-query-in-postgres
-	prices = applyFreqFilter(prices, freq)
+	prices, err := s.pricesWithIndicators(ctx, curCode, pSegments, f.Agg, f.Fill, cursor, points, indicators)
+	if err != nil {
+		s.log.Error("can not compute indicators for monitoring",
+			field.ID(f.ID), field.Any("form", f), field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not compute indicators for monitoring", err)
+
+		return
+	}
+
+	var nextCursor uint64 // 0 - last page
+	if !next.IsZero() {
+		nextCursor = uint64(next.UnixNano())
+	}
 
 	// TODO optional we can delete monitoring with that ID  (auto clean table, good idea imho)
-	if f.Delete {
+	if f.Delete && nextCursor == 0 { // only after the last page has been read
 		_, err = s.storage.Connector().Repo(m).Delete(ctx, f.ID)
 		if err != nil {
 			s.log.Error("can not deleter monitoring", field.ID(f.ID), field.Any("form", f), field.Error(err))
@@ -184,13 +401,550 @@ func (s *Server) GetMonitoring(c *gin.Context) { // TODO  toooo-looong func need
 
 	s.SendJSON(c, http.StatusOK, "Result of monitoring (time in UTC)",
 		gin.H{
-			"MonitoringID": f.ID,
-			"StartAt":      m.StartedAt,
-			"FinishedAt":   m.ExpiredAt,
-			"Prices":       convertToResponsePrices(prices),
+			"MonitoringID":    f.ID,
+			"Status":          m.Status,
+			"StatusChangedAt": m.StatusChangedAt,
+			"StartAt":         m.StartedAt,
+			"FinishedAt":      m.ExpiredAt,
+			"Labels":          m.Labels,
+			"Coverage":        coverage,
+			"Summary":         summary,
+			"Agg":             f.Agg,
+			"Fill":            f.Fill,
+			"Prices":          prices,
+			"NextCursor":      nextCursor,
+		})
+}
+
+// pricesPage - page of at most limit prices downsampled by agg after cursor (grid point of last price of previous
+// page) with gaps filled by fill (one point per frequency step). Return cursor of next page (zero for the last page).
+func (s *Server) pricesPage(
+	ctx context.Context,
+	curCode model.CurrencyCode,
+	segments []storage.PriceSegment,
+	agg, fill string,
+	limit uint64,
+	cursor time.Time,
+) ([]series.Point, time.Time, error) {
+	var next time.Time
+
+	if fill == series.FillNone {
+		// one more bucket to know if there is next page
+		buckets, err := storage.DownsamplePrices(ctx, s.storage, curCode, segments, agg, cursor, time.Time{}, limit+1)
+		if err != nil {
+			return nil, next, err
+		}
+
+		if uint64(len(buckets)) > limit {
+			buckets = buckets[:limit]
+			next = buckets[len(buckets)-1].Bucket
+		}
+
+		points := make([]series.Point, 0, len(buckets))
+		for _, b := range buckets {
+			points = append(points, series.Point{Time: b.Time, Value: b.Price, Observed: true})
+		}
+
+		return points, next, nil
+	}
+
+	steps := storage.PriceSteps(segments, cursor, limit+1)
+	if uint64(len(steps)) > limit {
+		steps = steps[:limit]
+		next = steps[len(steps)-1]
+	}
+
+	if len(steps) == 0 {
+		return nil, next, nil
+	}
+
+	// timestamps in db have microsecond precision
+	buckets, err := storage.DownsamplePrices(ctx, s.storage, curCode, segments, agg,
+		cursor, steps[len(steps)-1].Add(time.Microsecond), limit)
+	if err != nil {
+		return nil, next, err
+	}
+
+	observed := make([]series.Point, 0, len(buckets))
+	for _, b := range buckets {
+		observed = append(observed, series.Point{Time: b.Bucket, Value: b.Price, Observed: true})
+	}
+
+	var prev, last *series.Point
+
+	if fill != series.FillNull && (len(observed) == 0 || observed[0].Time.After(steps[0])) {
+		t, err := storage.NearestPriceTime(ctx, s.storage, curCode, segments[0].From, steps[0], true)
+		if err != nil {
+			return nil, next, err
+		}
+
+		if prev, err = s.observedStep(ctx, curCode, segments, agg, t); err != nil {
+			return nil, next, err
+		}
+	}
+
+	if fill == series.FillLinear && (len(observed) == 0 || observed[len(observed)-1].Time.Before(steps[len(steps)-1])) {
+		t, err := storage.NearestPriceTime(ctx, s.storage, curCode, steps[len(steps)-1],
+			segments[len(segments)-1].To, false)
+		if err != nil {
+			return nil, next, err
+		}
+
+		if last, err = s.observedStep(ctx, curCode, segments, agg, t); err != nil {
+			return nil, next, err
+		}
+	}
+
+	points, err := series.Fill(steps, observed, prev, last, fill)
+
+	return points, next, err
+}
+
+// pricesWithIndicators - prices of page with values of indicators. Indicators are computed over lookback prices before
+// page and prices of page (@see series.Lookback), they are null until warm-up period of indicator is passed.
+func (s *Server) pricesWithIndicators(
+	ctx context.Context,
+	curCode model.CurrencyCode,
+	segments []storage.PriceSegment,
+	agg, fill string,
+	cursor time.Time,
+	points []series.Point,
+	indicators []series.Indicator,
+) ([]ResponsePrice, error) {
+	prices := convertToResponsePrices(points)
+	if len(indicators) == 0 || len(points) == 0 {
+		return prices, nil
+	}
+
+	before, err := s.pricesBefore(ctx, curCode, segments, agg, fill, cursor, uint64(series.Lookback(indicators)))
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range prices {
+		prices[i].Indicators = make(map[string]*float64, len(indicators))
+	}
+
+	all := append(before, points...)
+
+	for _, ind := range indicators {
+		for _, line := range ind.Compute(all) {
+			for i, v := range line.Values[len(before):] {
+				prices[i].Indicators[line.Name] = v
+			}
+		}
+	}
+
+	return prices, nil
+}
+
+// pricesBefore - at most lookback last prices of window till cursor inclusive (the same as on previous pages).
+func (s *Server) pricesBefore(
+	ctx context.Context,
+	curCode model.CurrencyCode,
+	segments []storage.PriceSegment,
+	agg, fill string,
+	cursor time.Time,
+	lookback uint64,
+) ([]series.Point, error) {
+	if cursor.IsZero() || lookback == 0 {
+		return nil, nil
+	}
+
+	if fill != series.FillNone {
+		// one more step, page of lookback steps starts after it
+		steps := storage.PriceStepsBefore(segments, cursor, lookback+1)
+
+		var after time.Time
+		if uint64(len(steps)) > lookback {
+			after, steps = steps[0], steps[1:]
+		}
+
+		if len(steps) == 0 {
+			return nil, nil
+		}
+
+		points, _, err := s.pricesPage(ctx, curCode, segments, agg, fill, uint64(len(steps)), after)
+
+		return points, err
+	}
+
+	buckets, err := storage.DownsamplePricesBefore(ctx, s.storage, curCode, segments, agg,
+		cursor.Add(time.Microsecond), lookback)
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]series.Point, 0, len(buckets))
+	for _, b := range buckets {
+		points = append(points, series.Point{Time: b.Time, Value: b.Price, Observed: true})
+	}
+
+	return points, nil
+}
+
+// observedStep - downsampled price of step which contains price at time t, nil if t is zero.
+func (s *Server) observedStep(
+	ctx context.Context,
+	curCode model.CurrencyCode,
+	segments []storage.PriceSegment,
+	agg string,
+	t time.Time,
+) (*series.Point, error) {
+	if t.IsZero() {
+		return nil, nil
+	}
+
+	step, ok := storage.PriceStepOf(segments, agg, t)
+	if !ok {
+		return nil, nil
+	}
+
+	buckets, err := storage.DownsamplePrices(ctx, s.storage, curCode, segments, agg,
+		step.Add(-time.Microsecond), step.Add(time.Microsecond), 1)
+	if err != nil || len(buckets) == 0 {
+		return nil, err
+	}
+
+	return &series.Point{Time: step, Value: buckets[0].Price, Observed: true}, nil
+}
+
+// slotsCollector - count slots of frequency in [from, to) with at least one collected price (by db).
+func (s *Server) slotsCollector(ctx context.Context, curCode model.CurrencyCode) slotsCounter {
+	return func(from, to time.Time, freq time.Duration) (int, error) {
+		var cnt int
+
+		err := s.storage.PureSqlxDB().QueryRowxContext(ctx, fmt.Sprintf(
+			`SELECT COUNT(DISTINCT FLOOR(EXTRACT(EPOCH FROM time - $1) / $3)) FROM %s WHERE time >= $1 AND time < $2`,
+			model.PriceTableNameGetterFunc(curCode)), from, to, freq.Seconds(),
+		).Scan(&cnt)
+		if err != nil {
+			return 0, fmt.Errorf("can not count collected slots: %w", err)
+		}
+
+		return cnt, nil
+	}
+}
+
+// DeleteMonitoring godoc
+// @Summary Cancel monitoring
+// @Description cancel scheduled or running monitoring, its result is discarded
+// @Id DeleteMonitoring
+// @Tags Server API
+// @Param id path int true "id of monitoring"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.HTTPGoodResponse
+// @Failure 400 {object} util.HTTPErrorResponse
+// @Failure 404 {object} util.HTTPErrorResponse
+// @Failure 409 {object} util.HTTPErrorResponse
+// @Failure 500 {object} util.HTTPErrorResponse
+// @Router /api/v1/monitoring/{id} [delete]
+func (s *Server) DeleteMonitoring(c *gin.Context) {
+	ctx, cf := context.WithCancel(
+		helper.NewContextWithUUID(c.Copy().Request.Context(), c.GetString(mw.XRequestID)),
+	)
+	defer cf()
+
+	m, ok := s.bindAndGetMonitoring(ctx, c)
+	if !ok {
+		return
+	}
+
+	if err := model.ValidateStatusTransition(m.Status, model.StatusCancelled); err != nil {
+		s.SendErrorJSON(c, http.StatusConflict, "monitoring can not be cancelled in status "+m.Status, err)
+
+		return
+	}
+
+	changed, err := storage.TransitMonitoring(ctx, s.storage, m.ID, m.Status, model.StatusCancelled, "cancelled by client")
+	if err != nil {
+		s.log.Error("can not cancel monitoring", field.ID(m.ID), field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not cancel monitoring", err)
+
+		return
+	}
+
+	if !changed {
+		s.SendErrorJSON(c, http.StatusConflict, "monitoring status has been changed concurrently, try again", nil)
+
+		return
+	}
+
+	s.SendJSON(c, http.StatusOK, "Monitoring has been cancelled",
+		gin.H{
+			"MonitoringID": m.ID,
+			"Status":       model.StatusCancelled,
+		})
+}
+
+// PostMonitoringFinish godoc
+// @Summary Finish monitoring now
+// @Description finish running monitoring now (expired_at is truncated), result becomes available immediately
+// @Id PostMonitoringFinish
+// @Tags Server API
+// @Param id path int true "id of monitoring"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.HTTPGoodResponse
+// @Failure 400 {object} util.HTTPErrorResponse
+// @Failure 404 {object} util.HTTPErrorResponse
+// @Failure 409 {object} util.HTTPErrorResponse
+// @Failure 500 {object} util.HTTPErrorResponse
+// @Router /api/v1/monitoring/{id}/finish [post]
+func (s *Server) PostMonitoringFinish(c *gin.Context) {
+	ctx, cf := context.WithCancel(
+		helper.NewContextWithUUID(c.Copy().Request.Context(), c.GetString(mw.XRequestID)),
+	)
+	defer cf()
+
+	m, ok := s.bindAndGetMonitoring(ctx, c)
+	if !ok {
+		return
+	}
+
+	if m.Status != model.StatusRunning {
+		s.SendErrorJSON(c, http.StatusConflict, "only running monitoring can be finished, status is "+m.Status, nil)
+
+		return
+	}
+
+	curCode, err := s.getCurrencyCodeById(ctx, m.CurrencyID)
+	if err != nil {
+		s.log.Error("not found currency code by code id", field.Any("m", m), field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "not found currency code by code id", err)
+
+		return
+	}
+
+	finishedAt := time.Now().UTC()
+
+	status, err := storage.FinishMonitoring(ctx, s.storage, m.ID, curCode, finishedAt, "finished by client")
+	if err != nil {
+		s.log.Error("can not finish monitoring", field.ID(m.ID), field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not finish monitoring", err)
+
+		return
+	}
+
+	if status == "" {
+		s.SendErrorJSON(c, http.StatusConflict, "monitoring status has been changed concurrently, try again", nil)
+
+		return
+	}
+
+	if finishedAt.After(m.ExpiredAt) {
+		finishedAt = m.ExpiredAt
+	}
+
+	s.SendJSON(c, http.StatusOK, "Monitoring has been finished",
+		gin.H{
+			"MonitoringID": m.ID,
+			"Status":       status,
+			"FinishedAt":   finishedAt,
 		})
 }
 
+// PatchMonitoring godoc
+// @Summary Change monitoring
+// @Description extend expired_at, change frequency for the remaining window or move started_at of not started monitoring
+// @Id PatchMonitoring
+// @Tags Server API
+// @Param id path int true "id of monitoring"
+// @Param extend query string false "extend expired_at by duration like 10m"
+// @Param freq query string false "new frequency like 5s (for the remaining window)"
+// @Param start_at query string false "new started_at of not started monitoring (RFC3339)"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.HTTPGoodResponse
+// @Failure 400 {object} util.HTTPErrorResponse
+// @Failure 404 {object} util.HTTPErrorResponse
+// @Failure 409 {object} util.HTTPErrorResponse
+// @Failure 500 {object} util.HTTPErrorResponse
+// @Router /api/v1/monitoring/{id} [patch]
+func (s *Server) PatchMonitoring(c *gin.Context) {
+	ctx, cf := context.WithCancel(
+		helper.NewContextWithUUID(c.Copy().Request.Context(), c.GetString(mw.XRequestID)),
+	)
+	defer cf()
+
+	m, ok := s.bindAndGetMonitoring(ctx, c)
+	if !ok {
+		return
+	}
+
+	var f FormPatchMonitoring
+	if err := c.ShouldBindQuery(&f); err != nil {
+		s.log.Error("can not parse params (query)", field.ID(m.ID), field.Any("form", f), field.Error(err))
+		s.SendErrorJSON(c, http.StatusBadRequest, "can not parse params (query)", err)
+
+		return
+	}
+
+	if f.Extend == "" && f.Frequency == "" && f.StartAt.IsZero() {
+		s.SendErrorJSON(c, http.StatusBadRequest, "nothing to change, use extend, freq or start_at params", nil)
+
+		return
+	}
+
+	if m.Status != model.StatusScheduled && m.Status != model.StatusRunning {
+		s.SendErrorJSON(c, http.StatusConflict, "monitoring can not be changed in status "+m.Status, nil)
+
+		return
+	}
+
+	changed, status, err := applyMonitoringPatch(m, f, time.Now().UTC())
+	if err != nil {
+		s.SendErrorJSON(c, status, "bad values in form", err)
+
+		return
+	}
+
+	effectiveFrom := changed.StartedAt
+	if m.Status == model.StatusRunning {
+		effectiveFrom = time.Now().UTC()
+	}
+
+	ok, err = storage.ChangeMonitoring(ctx, s.storage, changed, effectiveFrom)
+	if err != nil {
+		s.log.Error("can not change monitoring", field.ID(m.ID), field.Any("form", f), field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not change monitoring", err)
+
+		return
+	}
+
+	if !ok {
+		s.SendErrorJSON(c, http.StatusConflict, "monitoring status has been changed concurrently, try again", nil)
+
+		return
+	}
+
+	s.SendJSON(c, http.StatusOK, "Monitoring has been changed",
+		gin.H{
+			"MonitoringID": changed.ID,
+			"Status":       changed.Status,
+			"StartAt":      changed.StartedAt,
+			"FinishedAt":   changed.ExpiredAt,
+			"Frequency":    changed.Frequency,
+		})
+}
+
+// applyMonitoringPatch - return changed monitoring or error with http status of response.
+func applyMonitoringPatch(m model.Monitoring, f FormPatchMonitoring, now time.Time) (model.Monitoring, int, error) {
+	if !f.StartAt.IsZero() {
+		if m.Status != model.StatusScheduled {
+			return m, http.StatusConflict, errors.New("started_at can be moved only for not started monitoring")
+		}
+
+		if f.StartAt.Before(now) {
+			return m, http.StatusBadRequest, errors.New("started_at can not be in the past")
+		}
+
+		period := m.ExpiredAt.Sub(m.StartedAt)
+		m.StartedAt = f.StartAt.UTC()
+		m.ExpiredAt = m.StartedAt.Add(period)
+	}
+
+	if f.Extend != "" {
+		extend, err := time.ParseDuration(f.Extend)
+		if err != nil || extend <= 0 {
+			return m, http.StatusBadRequest, fmt.Errorf("bad value for extend: %s", f.Extend)
+		}
+
+		m.ExpiredAt = m.ExpiredAt.Add(extend)
+	}
+
+	if f.Frequency != "" {
+		m.Frequency = f.Frequency
+	}
+
+	freq, err := time.ParseDuration(m.Frequency)
+	if err != nil {
+		return m, http.StatusBadRequest, fmt.Errorf("bad value for frequency: %w", err)
+	}
+
+	if err = validateMonitoringParams(m.ExpiredAt.Sub(m.StartedAt), freq); err != nil {
+		return m, http.StatusBadRequest, err
+	}
+
+	return m, http.StatusOK, nil
+}
+
+// waitMonitoring - get monitoring, if it has not finished yet, wait its finish at most wait (or till client is gone).
+func (s *Server) waitMonitoring(ctx context.Context, id model.Identity, wait time.Duration) (model.Monitoring, error) {
+	var m model.Monitoring
+
+	if wait <= 0 {
+		return m, s.storage.Connector().Repo(m).Get(ctx, id, &m)
+	}
+
+	timeout := time.NewTimer(wait)
+	defer timeout.Stop()
+
+	for {
+		finished, unsubscribe := s.waiter.Subscribe(id) // before get, so finish between get and waiting is not missed
+
+		if err := s.storage.Connector().Repo(m).Get(ctx, id, &m); err != nil || model.IsFinalStatus(m.Status) {
+			unsubscribe()
+
+			return m, err
+		}
+
+		select {
+		case <-finished: // status is checked again, wake up can be caused by reconnect of listener
+			unsubscribe()
+		case <-timeout.C:
+			unsubscribe()
+
+			return m, nil
+		case <-ctx.Done():
+			unsubscribe()
+
+			return m, nil
+		}
+	}
+}
+
+// validateMonitoringParams - validate period (window) and frequency of monitoring.
+func validateMonitoringParams(period, freq time.Duration) error {
+	if period <= 0 || period > maxMonitoringPeriod || freq < minMonitoringFrequency {
+		return fmt.Errorf("%w: period must be in (0, %s], freq must be >= %s",
+			errBadMonitoringParams, maxMonitoringPeriod, minMonitoringFrequency)
+	}
+
+	return nil
+}
+
+// bindAndGetMonitoring - bind id of monitoring from uri and get monitoring from db, send error response if failed.
+func (s *Server) bindAndGetMonitoring(ctx context.Context, c *gin.Context) (model.Monitoring, bool) {
+	var (
+		f FormMonitoringID
+		m model.Monitoring
+	)
+
+	if err := c.ShouldBindUri(&f); err != nil {
+		s.log.Error("can not parse params (uri)", field.ID(f.ID), field.Any("form", f), field.Error(err))
+		s.SendErrorJSON(c, http.StatusBadRequest, "can not parse params (uri)", err)
+
+		return m, false
+	}
+
+	if err := s.storage.Connector().Repo(m).Get(ctx, f.ID, &m); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.SendErrorJSON(c, http.StatusNotFound, "no monitoring obj with that id", nil)
+
+			return m, false
+		}
+
+		s.log.Error("can not get monitoring from db", field.ID(f.ID), field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not get monitoring from db", err)
+
+		return m, false
+	}
+
+	return m, true
+}
+
 func (s *Server) getCurrencyCodeById(ctx context.Context, id model.Identity) (string, error) {
 	cur := model.Currency{}
 	if err := s.storage.Connector().Repo(cur).Get(ctx, id, &cur); err != nil {
@@ -200,35 +954,107 @@ func (s *Server) getCurrencyCodeById(ctx context.Context, id model.Identity) (st
 	return cur.CurrencyCode, nil
 }
 
-func applyFreqFilter(prices []model.Price, freq time.Duration) []model.Price {
-	if len(prices) == 0 {
-		return prices
+// buildFreqSegments - build frequency segments from history of changes (in order of changes): every change overrides
+// frequency from its effective_from till the end of window. Segments are clamped to current start of window, because
+// scheduled monitoring can be rescheduled later (segments of earlier starts are stale).
+func buildFreqSegments(changes []model.MonitoringChange, start time.Time) ([]freqSegment, error) {
+	segments := make([]freqSegment, 0, len(changes))
+
+	for _, ch := range changes {
+		freq, err := time.ParseDuration(ch.Frequency)
+		if err != nil {
+			return nil, fmt.Errorf("bad frequency of change %d: %w", ch.ID, err)
+		}
+
+		for len(segments) > 0 && !segments[len(segments)-1].from.Before(ch.EffectiveFrom) {
+			segments = segments[:len(segments)-1]
+		}
+
+		if len(segments) > 0 && segments[len(segments)-1].freq == freq {
+			continue
+		}
+
+		segments = append(segments, freqSegment{from: ch.EffectiveFrom, freq: freq})
 	}
 
-	t := prices[0].Time
-	r := append(make([]model.Price, 0, len(prices)), prices[0])
+	for len(segments) > 1 && !segments[1].from.After(start) {
+		segments = segments[1:]
+	}
 
-	for _, v := range prices[1:] {
-		if v.Time.Before(t.Add(freq)) {
+	if len(segments) > 0 && segments[0].from.Before(start) {
+		segments[0].from = start
+	}
+
+	return segments, nil
+}
+
+// calcCoverage - calc coverage of window (from first segment till end) by collected prices. Every segment is
+// divided onto slots of its frequency, slot is covered if there is at least one price in it.
+func calcCoverage(segments []freqSegment, end time.Time, collected slotsCounter) (ResponseCoverage, error) {
+	var r ResponseCoverage
+
+	for i, seg := range segments {
+		segEnd := end
+		if i+1 < len(segments) {
+			segEnd = segments[i+1].from
+		}
+
+		if !segEnd.After(seg.from) {
 			continue
 		}
 
-		t = v.Time
+		r.Expected += int((segEnd.Sub(seg.from) + seg.freq - 1) / seg.freq)
+
+		cnt, err := collected(seg.from, segEnd, seg.freq)
+		if err != nil {
+			return r, err
+		}
+
+		r.Collected += cnt
+	}
+
+	if r.Expected > 0 {
+		r.Ratio = float64(r.Collected) / float64(r.Expected)
+	}
+
+	return r, nil
+}
+
+// priceSegments - segments of window till end for downsampling.
+func priceSegments(segments []freqSegment, end time.Time) []storage.PriceSegment {
+	r := make([]storage.PriceSegment, 0, len(segments))
+
+	for i, seg := range segments {
+		to := end
+		if i+1 < len(segments) && segments[i+1].from.Before(end) {
+			to = segments[i+1].from
+		}
+
+		if to.Before(seg.from) {
+			continue
+		}
 
-		r = append(r, v)
+		r = append(r, storage.PriceSegment{From: seg.from, To: to, Freq: seg.freq})
 	}
 
 	return r
 }
 
-func convertToResponsePrices(prices []model.Price) []ResponsePrice {
-	r := make([]ResponsePrice, 0, len(prices))
+func convertToResponsePrices(points []series.Point) []ResponsePrice {
+	r := make([]ResponsePrice, 0, len(points))
 
-	for _, v := range prices {
-		r = append(r, ResponsePrice{
-			Time:  v.Time,
-			Price: v.Price,
-		})
+	for _, v := range points {
+		p := ResponsePrice{
+			Time:     v.Time,
+			Observed: v.Observed,
+		}
+
+		if !v.Null {
+			price := v.Value
+			p.Price = &price
+		}
+
+		r = append(r, p)
 	}
 
 	return r
@@ -244,6 +1070,10 @@ func convertToResponsePrices(prices []model.Price) []ResponsePrice {
 // @Param cur query string true "currency code"
 // @Param period path string true "limit in time like 10m"
 // @Param freq path int string true "frequence like 5s"
+// @Param start_at query string false "scheduled start (RFC3339)"
+// @Param start_in query string false "scheduled start in duration like 10m"
+// @Param label query []string false "label key:value (several params allowed)"
+// @Param callback_url query string false "public http(s) url, signed result is posted there when monitoring finishes"
 // @Success 200 {object} util.HTTPGoodResponse
 // @Failure 400 {object} util.HTTPErrorResponse
 // @Failure 500 {object} util.HTTPErrorResponse
@@ -283,8 +1113,7 @@ func (s *Server) PostMonitoring(c *gin.Context) { // TODO  toooo-looong func nee
 		return
 	}
 
-	// TODO need clarify this, I add my constraints instead
-	if periodDuration > time.Hour*24 || freqDur < time.Second {
+	if err = validateMonitoringParams(periodDuration, freqDur); err != nil {
 		s.log.Error("period to much or freq too low", field.Any("form", f), field.Error(err))
 		s.SendErrorJSON(c, http.StatusBadRequest, "freqDur", err)
 
@@ -293,9 +1122,45 @@ func (s *Server) PostMonitoring(c *gin.Context) { // TODO  toooo-looong func nee
 
 	var m model.Monitoring
 	m.Frequency = f.Frequency
-	m.StartedAt = time.Now().UTC()
+
+	m.Labels, err = model.ParseLabels(f.Labels)
+	if err != nil {
+		s.SendErrorJSON(c, http.StatusBadRequest, "bad value for label", err)
+
+		return
+	}
+
+	if f.CallbackURL != "" {
+		if err = s.callbacks.ValidateURL(ctx, f.CallbackURL); err != nil {
+			s.SendErrorJSON(c, http.StatusBadRequest, "bad value for callback_url", err)
+
+			return
+		}
+
+		m.CallbackURL = f.CallbackURL
+	}
+
+	now := time.Now().UTC()
+
+	m.StartedAt, err = monitoringStart(f, now)
+	if err != nil {
+		s.log.Error("bad value for start", field.Any("form", f), field.Error(err))
+		s.SendErrorJSON(c, http.StatusBadRequest, "bad value for start", err)
+
+		return
+	}
+
 	m.ExpiredAt = m.StartedAt.Add(periodDuration)
 
+	// window in the past - historical monitoring over already collected prices, it's finished immediately
+	isHistorical := !m.ExpiredAt.After(now)
+	if m.StartedAt.Before(now) && !isHistorical {
+		s.SendErrorJSON(c, http.StatusBadRequest, "bad value for start",
+			errors.New("window of monitoring must be entirely in the past or start not earlier than now"))
+
+		return
+	}
+
 	m.CurrencyID, err = s.getCurrencyIdByCurrencyCode(ctx, f.Currency)
 	if err != nil {
 		s.log.Error("can not get data currency data from db", field.Any("form", f), field.Error(err))
@@ -305,7 +1170,12 @@ func (s *Server) PostMonitoring(c *gin.Context) { // TODO  toooo-looong func nee
 		return
 	}
 
-	id, err := s.storage.Connector().Repo(m).Create(ctx, m)
+	m.Status = model.StatusScheduled
+	if isHistorical {
+		m.Status = model.StatusRunning
+	}
+
+	id, err := storage.CreateMonitoring(ctx, s.storage, m, "created by client")
 	if err != nil {
 		s.log.Error("can not create new monitor obj", field.Any("m", m), field.Error(err))
 		s.SendErrorJSON(c, http.StatusInternalServerError, "can not create new monitor obj", err)
@@ -313,13 +1183,83 @@ func (s *Server) PostMonitoring(c *gin.Context) { // TODO  toooo-looong func nee
 		return
 	}
 
+	if isHistorical {
+		s.finishHistoricalMonitoring(ctx, c, id, m, strings.ToUpper(f.Currency), freqDur)
+
+		return
+	}
+
 	s.SendJSON(c, http.StatusOK, "Successfully created new monitoring",
 		gin.H{
 			"MonitoringID": id,
+			"Status":       m.Status,
+			"StartAt":      m.StartedAt,
+			"FinishedAt":   m.ExpiredAt,
 		})
 
 }
 
+// finishHistoricalMonitoring - finish created historical monitoring by already collected prices, report coverage.
+func (s *Server) finishHistoricalMonitoring(
+	ctx context.Context,
+	c *gin.Context,
+	id int64,
+	m model.Monitoring,
+	curCode model.CurrencyCode,
+	freq time.Duration,
+) {
+	status, err := storage.FinishMonitoring(ctx, s.storage, id, curCode, m.ExpiredAt, "historical window is in the past")
+	if err != nil {
+		s.log.Error("can not finish historical monitoring", field.ID(id), field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not finish historical monitoring", err)
+
+		return
+	}
+
+	coverage, err := calcCoverage([]freqSegment{{from: m.StartedAt, freq: freq}}, m.ExpiredAt,
+		s.slotsCollector(ctx, curCode))
+	if err != nil {
+		s.log.Error("can not calc coverage of monitoring", field.ID(id), field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not calc coverage of monitoring", err)
+
+		return
+	}
+
+	s.SendJSON(c, http.StatusOK, "Successfully created historical monitoring",
+		gin.H{
+			"MonitoringID": id,
+			"Status":       status,
+			"StartAt":      m.StartedAt,
+			"FinishedAt":   m.ExpiredAt,
+			"Coverage":     coverage,
+		})
+}
+
+// monitoringStart - start time of new monitoring: now or scheduled by start_at (absolute) or start_in (relative).
+func monitoringStart(f FormPostMonitoring, now time.Time) (time.Time, error) {
+	switch {
+	case !f.StartAt.IsZero() && f.StartIn != "":
+		return now, errors.New("only one of start_at and start_in can be set")
+
+	case !f.StartAt.IsZero():
+		return f.StartAt.UTC(), nil // in the past for historical monitoring
+
+	case f.StartIn != "":
+		startIn, err := time.ParseDuration(f.StartIn)
+		if err != nil {
+			return now, fmt.Errorf("bad value for start_in: %w", err)
+		}
+
+		if startIn < 0 {
+			return now, errors.New("start_in can not be negative")
+		}
+
+		return now.Add(startIn), nil
+	}
+
+	return now, nil
+}
+
 // todo refactor (optimize and cache value for currency code in hash map
 func (s *Server) getCurrencyIdByCurrencyCode(ctx context.Context, currency string) (model.Identity, error) {
 	currencyCode := func(s string) model.CurrencyCode {
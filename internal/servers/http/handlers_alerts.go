@@ -0,0 +1,305 @@
+package http
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/imperiuse/price_monitor/internal/helper"
+	"github.com/imperiuse/price_monitor/internal/logger/field"
+	mw "github.com/imperiuse/price_monitor/internal/servers/http/middlerware"
+	"github.com/imperiuse/price_monitor/internal/services/storage"
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+const defaultEventsLimit = 100
+
+type (
+	FormPostAlertRule struct {
+		Currency   string   `form:"cur" binding:"required,min=3,max=10"` // btcusd
+		Kind       string   `form:"kind" binding:"required,oneof=cross_above cross_below change"`
+		Threshold  float64  `form:"threshold" binding:"required,gt=0"`         // price or percent of move for change
+		Window     string   `form:"window" binding:"omitempty,min=2,max=10"`   // 10m, only for change
+		Hysteresis float64  `form:"hysteresis" binding:"omitempty,min=0"`      // price or percent points
+		Cooldown   string   `form:"cooldown" binding:"omitempty,min=2,max=10"` // 5m
+		Channels   []string `form:"channel" binding:"required,max=8"`          // names of channels from config
+	}
+
+	FormGetAlertRules struct {
+		Currency string `form:"cur" binding:"omitempty,min=3,max=10"` // btcusd
+	}
+
+	FormAlertRuleID struct {
+		ID int64 `uri:"id" binding:"required,min=1,max=9223372036854775807"`
+	}
+
+	FormGetAlertEvents struct {
+		RuleID   int64  `form:"rule" binding:"omitempty,min=1,max=9223372036854775807"`
+		Currency string `form:"cur" binding:"omitempty,min=3,max=10"`                      // btcusd
+		Cursor   uint64 `form:"cursor" binding:"omitempty,min=0,max=18446744073709551615"` // NextCursor of previous page
+		Limit    uint64 `form:"limit" binding:"omitempty,min=1,max=1000"`
+	}
+)
+
+// PostAlertRule godoc
+// @Summary Create alert rule
+// @Description create alert rule on prices, it's evaluated by scanner on every new sample of price
+// @Id PostAlertRule
+// @Tags Server API
+// @Accept  json
+// @Produce  json
+// @Param cur query string true "currency code"
+// @Param kind query string true "cross_above, cross_below (price crosses threshold) or change (moves within window)"
+// @Param threshold query number true "price for cross rules, percent of move for change rule"
+// @Param window query string false "window of move for change rule like 10m"
+// @Param hysteresis query number false "distance from threshold (price or percent points) to re-arm fired rule"
+// @Param cooldown query string false "min interval between fires like 5m"
+// @Param channel query []string true "notification channel from config (several params allowed)"
+// @Success 200 {object} util.HTTPGoodResponse
+// @Failure 400 {object} util.HTTPErrorResponse
+// @Failure 500 {object} util.HTTPErrorResponse
+// @Router /api/v1/alerts [post]
+func (s *Server) PostAlertRule(c *gin.Context) {
+	ctx, cf := context.WithCancel(
+		helper.NewContextWithUUID(c.Copy().Request.Context(), c.GetString(mw.XRequestID)),
+	)
+	defer cf()
+
+	var f FormPostAlertRule
+	if c.Bind(&f) != nil {
+		return
+	}
+
+	if f.Cooldown == "" {
+		f.Cooldown = "0s"
+	}
+
+	r := model.AlertRule{
+		CreatedAt:  time.Now().UTC(),
+		Kind:       f.Kind,
+		Threshold:  f.Threshold,
+		Window:     f.Window,
+		Hysteresis: f.Hysteresis,
+		Cooldown:   f.Cooldown,
+		Channels:   f.Channels,
+	}
+
+	if err := s.alerts.ValidateRule(r); err != nil {
+		s.SendErrorJSON(c, http.StatusBadRequest, "bad values in form", err)
+
+		return
+	}
+
+	var err error
+
+	r.CurrencyID, err = s.getCurrencyIdByCurrencyCode(ctx, strings.ToUpper(f.Currency))
+	if err != nil {
+		s.log.Error("can not get data currency data from db", field.Any("form", f), field.Error(err))
+		s.SendErrorJSON(c, http.StatusBadRequest, "can not get data currency data from db."+
+			" probably you try to create alert rule for unsupported currency", err)
+
+		return
+	}
+
+	id, err := s.storage.Connector().Repo(r).Create(ctx, r)
+	if err != nil {
+		s.log.Error("can not create new alert rule", field.Any("r", r), field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not create new alert rule", err)
+
+		return
+	}
+
+	s.SendJSON(c, http.StatusOK, "Successfully created new alert rule",
+		gin.H{
+			"AlertRuleID": id,
+		})
+}
+
+// GetAlertRules godoc
+// @Summary Get alert rules
+// @Description get alert rules with their state (armed, last fire)
+// @Id GetAlertRules
+// @Tags Server API
+// @Param cur query string false "currency code"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.HTTPGoodResponse
+// @Failure 400 {object} util.HTTPErrorResponse
+// @Failure 500 {object} util.HTTPErrorResponse
+// @Router /api/v1/alerts [get]
+func (s *Server) GetAlertRules(c *gin.Context) {
+	ctx, cf := context.WithCancel(
+		helper.NewContextWithUUID(c.Copy().Request.Context(), c.GetString(mw.XRequestID)),
+	)
+	defer cf()
+
+	var f FormGetAlertRules
+	if err := c.ShouldBindQuery(&f); err != nil {
+		s.SendErrorJSON(c, http.StatusBadRequest, "can not parse params (query)", err)
+
+		return
+	}
+
+	query := storage.Select("*").OrderBy("id")
+
+	if f.Currency != "" {
+		curID, err := s.getCurrencyIdByCurrencyCode(ctx, strings.ToUpper(f.Currency))
+		if err != nil {
+			s.SendErrorJSON(c, http.StatusBadRequest, "bad value for currency", err)
+
+			return
+		}
+
+		query = query.Where(storage.Eq{"currency_id": curID})
+	}
+
+	rules := make([]model.AlertRule, 0)
+	if err := s.storage.Connector().Repo(model.AlertRule{}).Select(ctx, query, &rules); err != nil {
+		s.log.Error("can not get alert rules", field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not get alert rules", err)
+
+		return
+	}
+
+	s.SendJSON(c, http.StatusOK, "Alert rules (time in UTC)",
+		gin.H{
+			"AlertRules": rules,
+		})
+}
+
+// DeleteAlertRule godoc
+// @Summary Delete alert rule
+// @Description delete alert rule, history of its fired alerts is kept
+// @Id DeleteAlertRule
+// @Tags Server API
+// @Param id path int true "id of alert rule"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.HTTPGoodResponse
+// @Failure 400 {object} util.HTTPErrorResponse
+// @Failure 404 {object} util.HTTPErrorResponse
+// @Failure 500 {object} util.HTTPErrorResponse
+// @Router /api/v1/alerts/{id} [delete]
+func (s *Server) DeleteAlertRule(c *gin.Context) {
+	ctx, cf := context.WithCancel(
+		helper.NewContextWithUUID(c.Copy().Request.Context(), c.GetString(mw.XRequestID)),
+	)
+	defer cf()
+
+	var (
+		f FormAlertRuleID
+		r model.AlertRule
+	)
+
+	if err := c.ShouldBindUri(&f); err != nil {
+		s.SendErrorJSON(c, http.StatusBadRequest, "can not parse params (uri)", err)
+
+		return
+	}
+
+	if err := s.storage.Connector().Repo(r).Get(ctx, f.ID, &r); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.SendErrorJSON(c, http.StatusNotFound, "no alert rule with that id", nil)
+
+			return
+		}
+
+		s.log.Error("can not get alert rule from db", field.ID(f.ID), field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not get alert rule from db", err)
+
+		return
+	}
+
+	if _, err := s.storage.Connector().Repo(r).Delete(ctx, r.ID); err != nil {
+		s.log.Error("can not delete alert rule", field.ID(r.ID), field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not delete alert rule", err)
+
+		return
+	}
+
+	s.SendJSON(c, http.StatusOK, "Alert rule has been deleted",
+		gin.H{
+			"AlertRuleID": r.ID,
+		})
+}
+
+// GetAlertEvents godoc
+// @Summary Get history of fired alerts
+// @Description fired alerts (last first), keyset pagination
+// @Id GetAlertEvents
+// @Tags Server API
+// @Param rule query int false "id of alert rule"
+// @Param cur query string false "currency code"
+// @Param cursor query int false "NextCursor from previous page"
+// @Param limit query int false "limit of page"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.HTTPGoodResponse
+// @Failure 400 {object} util.HTTPErrorResponse
+// @Failure 500 {object} util.HTTPErrorResponse
+// @Router /api/v1/alerts/events [get]
+func (s *Server) GetAlertEvents(c *gin.Context) {
+	ctx, cf := context.WithCancel(
+		helper.NewContextWithUUID(c.Copy().Request.Context(), c.GetString(mw.XRequestID)),
+	)
+	defer cf()
+
+	var f FormGetAlertEvents
+	if err := c.ShouldBindQuery(&f); err != nil {
+		s.SendErrorJSON(c, http.StatusBadRequest, "can not parse params (query)", err)
+
+		return
+	}
+
+	if f.Limit == 0 {
+		f.Limit = defaultEventsLimit
+	}
+
+	conds := storage.And{}
+
+	if f.RuleID != 0 {
+		conds = append(conds, storage.Eq{"rule_id": f.RuleID})
+	}
+
+	if f.Currency != "" {
+		curID, err := s.getCurrencyIdByCurrencyCode(ctx, strings.ToUpper(f.Currency))
+		if err != nil {
+			s.SendErrorJSON(c, http.StatusBadRequest, "bad value for currency", err)
+
+			return
+		}
+
+		conds = append(conds, storage.Eq{"currency_id": curID})
+	}
+
+	if f.Cursor != 0 {
+		conds = append(conds, storage.Lt{"id": f.Cursor})
+	}
+
+	events := make([]model.AlertEvent, 0, f.Limit)
+	if err := s.storage.Connector().Repo(model.AlertEvent{}).Select(ctx,
+		storage.Select("*").Where(conds).OrderBy("id DESC").Limit(f.Limit),
+		&events,
+	); err != nil {
+		s.log.Error("can not get alert events", field.Any("form", f), field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not get alert events", err)
+
+		return
+	}
+
+	var nextCursor model.Identity // 0 - last page
+	if uint64(len(events)) == f.Limit {
+		nextCursor = events[len(events)-1].ID
+	}
+
+	s.SendJSON(c, http.StatusOK, "Fired alerts (time in UTC)",
+		gin.H{
+			"AlertEvents": events,
+			"NextCursor":  nextCursor,
+		})
+}
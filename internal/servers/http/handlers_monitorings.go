@@ -0,0 +1,134 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/imperiuse/price_monitor/internal/helper"
+	"github.com/imperiuse/price_monitor/internal/logger/field"
+	mw "github.com/imperiuse/price_monitor/internal/servers/http/middlerware"
+	"github.com/imperiuse/price_monitor/internal/services/storage"
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+const defaultListLimit = 100
+
+type FormGetMonitorings struct {
+	Currency  string    `form:"cur" binding:"omitempty,min=3,max=10"` // btcusd
+	Statuses  []string  `form:"status" binding:"omitempty,max=5,dive,oneof=scheduled running completed cancelled failed"`
+	From      time.Time `form:"from" binding:"omitempty" time_format:"2006-01-02T15:04:05Z07:00"` // RFC3339
+	To        time.Time `form:"to" binding:"omitempty" time_format:"2006-01-02T15:04:05Z07:00"`   // RFC3339
+	Frequency string    `form:"freq" binding:"omitempty,min=2,max=10"`                            // 1s, 5s, 1m
+	Labels    []string  `form:"label" binding:"omitempty"`                                        // key:value
+	Sort      string    `form:"sort" binding:"omitempty,oneof=created_at expired_at"`
+	Order     string    `form:"order" binding:"omitempty,oneof=asc desc"`
+	Cursor    string    `form:"cursor" binding:"omitempty,max=100"` // NextCursor of previous page
+	Limit     uint64    `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
+
+// GetMonitorings godoc
+// @Summary List monitorings
+// @Description list of monitorings filtered by currency, statuses, time range, frequency and labels (keyset pagination)
+// @Id GetMonitorings
+// @Tags Server API
+// @Param cur query string false "currency code"
+// @Param status query []string false "statuses of monitoring (several params allowed)"
+// @Param from query string false "window of monitoring ends after (RFC3339)"
+// @Param to query string false "window of monitoring starts before (RFC3339)"
+// @Param freq query string false "frequency like 5s"
+// @Param label query []string false "label key:value, monitoring must have all of them (several params allowed)"
+// @Param sort query string false "created_at (default) or expired_at"
+// @Param order query string false "asc (default) or desc"
+// @Param cursor query string false "NextCursor from previous page (opaque)"
+// @Param limit query int false "limit of page"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.HTTPGoodResponse
+// @Failure 400 {object} util.HTTPErrorResponse
+// @Failure 500 {object} util.HTTPErrorResponse
+// @Router /api/v1/monitorings [get]
+func (s *Server) GetMonitorings(c *gin.Context) {
+	ctx, cf := context.WithCancel(
+		helper.NewContextWithUUID(c.Copy().Request.Context(), c.GetString(mw.XRequestID)),
+	)
+	defer cf()
+
+	var f FormGetMonitorings
+	if err := c.ShouldBindQuery(&f); err != nil {
+		s.SendErrorJSON(c, http.StatusBadRequest, "can not parse params (query)", err)
+
+		return
+	}
+
+	filter, err := s.monitoringsFilter(ctx, f)
+	if err != nil {
+		s.SendErrorJSON(c, http.StatusBadRequest, "bad values in form", err)
+
+		return
+	}
+
+	if f.Sort == "" {
+		f.Sort = storage.SortByCreatedAt
+	}
+
+	if f.Limit == 0 {
+		f.Limit = defaultListLimit
+	}
+
+	after, err := storage.ParseMonitoringsCursor(f.Cursor)
+	if err == nil && after.ID != 0 && after.SortBy != f.Sort {
+		err = fmt.Errorf("%w: cursor of list sorted by %s", storage.ErrBadCursor, after.SortBy)
+	}
+
+	if err != nil {
+		s.SendErrorJSON(c, http.StatusBadRequest, "bad value for cursor", err)
+
+		return
+	}
+
+	monitorings, err := storage.ListMonitorings(ctx, s.storage, filter, f.Sort, after, f.Limit, f.Order == "desc")
+	if err != nil {
+		s.log.Error("can not get monitorings", field.Any("form", f), field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not get monitorings", err)
+
+		return
+	}
+
+	var nextCursor string // "" - last page
+	if uint64(len(monitorings)) == f.Limit {
+		nextCursor = storage.NewMonitoringsCursor(monitorings[len(monitorings)-1], f.Sort).String()
+	}
+
+	s.SendJSON(c, http.StatusOK, "Monitorings (time in UTC)",
+		gin.H{
+			"Monitorings": monitorings,
+			"NextCursor":  nextCursor,
+		})
+}
+
+func (s *Server) monitoringsFilter(ctx context.Context, f FormGetMonitorings) (storage.MonitoringsFilter, error) {
+	filter := storage.MonitoringsFilter{
+		Statuses:  f.Statuses,
+		From:      f.From.UTC(), // zero time stays zero
+		To:        f.To.UTC(),
+		Frequency: f.Frequency,
+	}
+
+	var err error
+
+	if f.Currency != "" {
+		filter.CurrencyID, err = s.getCurrencyIdByCurrencyCode(ctx, strings.ToUpper(f.Currency))
+		if err != nil {
+			return filter, err
+		}
+	}
+
+	filter.Labels, err = model.ParseLabels(f.Labels)
+
+	return filter, err
+}
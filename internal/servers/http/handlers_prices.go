@@ -0,0 +1,281 @@
+package http
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/imperiuse/price_monitor/internal/helper"
+	"github.com/imperiuse/price_monitor/internal/logger/field"
+	"github.com/imperiuse/price_monitor/internal/series"
+	mw "github.com/imperiuse/price_monitor/internal/servers/http/middlerware"
+	"github.com/imperiuse/price_monitor/internal/services/storage"
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+const (
+	defaultCandlesLimit = 1000
+
+	minCandleInterval = time.Second
+	maxCandleInterval = 7 * 24 * time.Hour
+)
+
+var errUnsupportedCurrency = errors.New("unsupported currency")
+
+type (
+	FormCurrency struct {
+		Currency string `uri:"currency" binding:"required,min=3,max=10"` // btcusd
+	}
+
+	FormGetCandles struct {
+		Interval string    `form:"interval" binding:"required,min=2,max=10"`                        // 1m, 15m, 1h
+		From     time.Time `form:"from" binding:"required" time_format:"2006-01-02T15:04:05Z07:00"` // RFC3339
+		To       time.Time `form:"to" binding:"omitempty" time_format:"2006-01-02T15:04:05Z07:00"`  // RFC3339, now by default
+		Limit    uint64    `form:"limit" binding:"omitempty,min=1,max=10000"`
+	}
+
+	FormGetPrices struct {
+		From   time.Time `form:"from" binding:"required" time_format:"2006-01-02T15:04:05Z07:00"` // RFC3339
+		To     time.Time `form:"to" binding:"omitempty" time_format:"2006-01-02T15:04:05Z07:00"`  // RFC3339, now by default
+		Step   string    `form:"step" binding:"required,min=2,max=10"`                            // 1s, 5s, 1m
+		Agg    string    `form:"agg"  binding:"omitempty,oneof=first last avg closest"`
+		Fill   string    `form:"fill"  binding:"omitempty,oneof=none previous linear null"`
+		Cursor uint64    `form:"cursor"  binding:"omitempty,min=0,max=18446744073709551615"` // NextCursor of previous page
+		Limit  uint64    `form:"limit"  binding:"omitempty,min=1,max=10000"`
+
+		Indicators string `form:"indicators"  binding:"omitempty,max=200"` // sma:20,ema:50,rsi:14,bbands:20
+	}
+)
+
+// GetPrices godoc
+// @Summary Get prices
+// @Description prices of window [from, to] downsampled to one price per step (without monitoring), the same limits
+// @Description as for monitoring: window is not longer than 24h, step is not less than 1s. Prices older than
+// @Description price retention of cleaner (30 days by default) are kept only if a retained monitoring covers them
+// @Id GetPrices
+// @Tags Server API
+// @Param currency path string true "currency code"
+// @Param from query string true "start of window (RFC3339)"
+// @Param to query string false "end of window (RFC3339), now by default"
+// @Param step query string true "step like 10s"
+// @Param agg query string false "sample of every step: first (default), last, avg, closest (to step start)"
+// @Param fill query string false "filling of steps without prices: none (default), previous, linear, null"
+// @Param cursor query int false "NextCursor from previous page of prices"
+// @Param limit query int false "limit of prices in page"
+// @Param indicators query string false "indicators of prices like sma:20,ema:50,rsi:14,bbands:20"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.HTTPGoodResponse
+// @Failure 400 {object} util.HTTPErrorResponse
+// @Failure 404 {object} util.HTTPErrorResponse
+// @Failure 500 {object} util.HTTPErrorResponse
+// @Router /api/v1/prices/{currency} [get]
+func (s *Server) GetPrices(c *gin.Context) {
+	ctx, cf := context.WithCancel(
+		helper.NewContextWithUUID(c.Copy().Request.Context(), c.GetString(mw.XRequestID)),
+	)
+	defer cf()
+
+	curCode, ok := s.bindCurrency(c)
+	if !ok {
+		return
+	}
+
+	var f FormGetPrices
+	if err := c.ShouldBindQuery(&f); err != nil {
+		s.SendErrorJSON(c, http.StatusBadRequest, "can not parse params (query)", err)
+
+		return
+	}
+
+	step, err := time.ParseDuration(f.Step)
+	if err != nil {
+		s.SendErrorJSON(c, http.StatusBadRequest, "bad value for step", err)
+
+		return
+	}
+
+	if f.To.IsZero() {
+		f.To = time.Now()
+	}
+
+	from, to := f.From.UTC(), f.To.UTC()
+	if !from.Before(to) {
+		s.SendErrorJSON(c, http.StatusBadRequest, "bad values in form", errors.New("from must be before to"))
+
+		return
+	}
+
+	if err = validateMonitoringParams(to.Sub(from), step); err != nil {
+		s.SendErrorJSON(c, http.StatusBadRequest, "bad values in form", err)
+
+		return
+	}
+
+	if f.Limit == 0 {
+		f.Limit = defaultLimit
+	}
+
+	if f.Agg == "" {
+		f.Agg = storage.AggFirst
+	}
+
+	if f.Fill == "" {
+		f.Fill = series.FillNone
+	}
+
+	indicators, err := series.ParseIndicators(f.Indicators)
+	if err != nil {
+		s.SendErrorJSON(c, http.StatusBadRequest, "bad value for indicators", err)
+
+		return
+	}
+
+	var cursor time.Time // keyset on grid point of last price of previous page
+	if f.Cursor != 0 {
+		cursor = time.Unix(0, int64(f.Cursor)).UTC()
+	}
+
+	segments := []storage.PriceSegment{{From: from, To: to, Freq: step}}
+
+	points, next, err := s.pricesPage(ctx, curCode, segments, f.Agg, f.Fill, f.Limit, cursor)
+	if err != nil {
+		s.log.Error("can not get prices", field.String("currency", curCode), field.Any("form", f), field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not get prices", err)
+
+		return
+	}
+
+	prices, err := s.pricesWithIndicators(ctx, curCode, segments, f.Agg, f.Fill, cursor, points, indicators)
+	if err != nil {
+		s.log.Error("can not compute indicators", field.String("currency", curCode), field.Any("form", f),
+			field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not compute indicators", err)
+
+		return
+	}
+
+	var nextCursor uint64 // 0 - last page
+	if !next.IsZero() {
+		nextCursor = uint64(next.UnixNano())
+	}
+
+	s.SendJSON(c, http.StatusOK, "Prices (time in UTC)",
+		gin.H{
+			"Currency":   curCode,
+			"From":       from,
+			"To":         to,
+			"Step":       step.String(),
+			"Agg":        f.Agg,
+			"Fill":       f.Fill,
+			"Prices":     prices,
+			"NextCursor": nextCursor,
+		})
+}
+
+// GetCandles godoc
+// @Summary Get OHLC candles of prices
+// @Description OHLC candles with sample counts, common intervals (1m, 1h, 1d) are read from continuous aggregates
+// @Id GetCandles
+// @Tags Server API
+// @Param currency path string true "currency code"
+// @Param interval query string true "interval of candle like 1m, 15m, 1h"
+// @Param from query string true "candles starting from (RFC3339)"
+// @Param to query string false "candles starting before (RFC3339), now by default"
+// @Param limit query int false "limit of candles"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.HTTPGoodResponse
+// @Failure 400 {object} util.HTTPErrorResponse
+// @Failure 404 {object} util.HTTPErrorResponse
+// @Failure 500 {object} util.HTTPErrorResponse
+// @Router /api/v1/prices/{currency}/candles [get]
+func (s *Server) GetCandles(c *gin.Context) {
+	ctx, cf := context.WithCancel(
+		helper.NewContextWithUUID(c.Copy().Request.Context(), c.GetString(mw.XRequestID)),
+	)
+	defer cf()
+
+	curCode, ok := s.bindCurrency(c)
+	if !ok {
+		return
+	}
+
+	var f FormGetCandles
+	if err := c.ShouldBindQuery(&f); err != nil {
+		s.SendErrorJSON(c, http.StatusBadRequest, "can not parse params (query)", err)
+
+		return
+	}
+
+	interval, err := time.ParseDuration(f.Interval)
+	if err != nil || interval < minCandleInterval || interval > maxCandleInterval {
+		s.SendErrorJSON(c, http.StatusBadRequest, "bad value for interval",
+			fmt.Errorf("interval must be in [%s, %s]: %q", minCandleInterval, maxCandleInterval, f.Interval))
+
+		return
+	}
+
+	if f.To.IsZero() {
+		f.To = time.Now()
+	}
+
+	from, to := f.From.UTC(), f.To.UTC()
+	if !from.Before(to) {
+		s.SendErrorJSON(c, http.StatusBadRequest, "bad values in form", errors.New("from must be before to"))
+
+		return
+	}
+
+	if f.Limit == 0 {
+		f.Limit = defaultCandlesLimit
+	}
+
+	candles, err := storage.SelectCandles(ctx, s.storage, curCode, interval, from, to, f.Limit)
+	if err != nil {
+		s.log.Error("can not get candles", field.String("currency", curCode), field.Any("form", f), field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not get candles", err)
+
+		return
+	}
+
+	var nextFrom *time.Time // nil - there are no more candles in [from, to)
+	if uint64(len(candles)) == f.Limit {
+		next := candles[len(candles)-1].Time.Add(interval)
+		nextFrom = &next
+	}
+
+	s.SendJSON(c, http.StatusOK, "OHLC candles (time in UTC)",
+		gin.H{
+			"Currency": curCode,
+			"Interval": interval.String(),
+			"Candles":  candles,
+			"NextFrom": nextFrom,
+		})
+}
+
+// bindCurrency - bind currency code from uri, send error response if it's not supported.
+func (s *Server) bindCurrency(c *gin.Context) (model.CurrencyCode, bool) {
+	var f FormCurrency
+	if err := c.ShouldBindUri(&f); err != nil {
+		s.SendErrorJSON(c, http.StatusBadRequest, "can not parse params (uri)", err)
+
+		return "", false
+	}
+
+	for _, code := range model.Currencies {
+		if strings.EqualFold(code, f.Currency) {
+			return code, true
+		}
+	}
+
+	s.SendErrorJSON(c, http.StatusNotFound, "bad value for currency",
+		fmt.Errorf("%w: %q", errUnsupportedCurrency, f.Currency))
+
+	return "", false
+}
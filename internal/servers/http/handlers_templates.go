@@ -0,0 +1,343 @@
+package http
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/imperiuse/price_monitor/internal/helper"
+	"github.com/imperiuse/price_monitor/internal/logger/field"
+	mw "github.com/imperiuse/price_monitor/internal/servers/http/middlerware"
+	"github.com/imperiuse/price_monitor/internal/services/controllers/master/scheduler"
+	"github.com/imperiuse/price_monitor/internal/services/storage"
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+const defaultRunsLimit = 100
+
+type (
+	FormPostTemplate struct {
+		Cron      string `form:"cron" binding:"required,max=100"`        // 0 9 * * 1-5, @hourly, CRON_TZ=UTC 0 9 * * *
+		Period    string `form:"period" binding:"required,min=2,max=10"` // 30s, 1m, 1h
+		Frequency string `form:"freq" binding:"required,min=2,max=10"`   // 1s, 5s, 1m
+		Currency  string `form:"cur" binding:"required,min=3,max=10"`    // btcusd
+	}
+
+	FormTemplateID struct {
+		ID int64 `uri:"id" binding:"required,min=1,max=9223372036854775807"`
+	}
+
+	FormGetTemplateRuns struct {
+		Limit uint64 `form:"limit"  binding:"omitempty,min=1,max=10000"`
+	}
+)
+
+// PostTemplate godoc
+// @Summary Create template of recurring monitorings
+// @Description create template, monitorings (runs) are created from it by cron schedule on master node
+// @Id PostTemplate
+// @Tags Server API
+// @Accept  json
+// @Produce  json
+// @Param cron query string true "cron expression like 0 9 * * 1-5 (CRON_TZ= prefix), at most once a minute"
+// @Param cur query string true "currency code"
+// @Param period query string true "window of every run like 1h"
+// @Param freq query string true "frequency like 30s"
+// @Success 200 {object} util.HTTPGoodResponse
+// @Failure 400 {object} util.HTTPErrorResponse
+// @Failure 500 {object} util.HTTPErrorResponse
+// @Router /api/v1/templates [post]
+func (s *Server) PostTemplate(c *gin.Context) {
+	ctx, cf := context.WithCancel(
+		helper.NewContextWithUUID(c.Copy().Request.Context(), c.GetString(mw.XRequestID)),
+	)
+	defer cf()
+
+	var f FormPostTemplate
+	if c.Bind(&f) != nil {
+		return
+	}
+
+	t := model.MonitoringTemplate{
+		CreatedAt: time.Now().UTC(),
+		Cron:      f.Cron,
+		Period:    f.Period,
+		Frequency: f.Frequency,
+	}
+
+	schedule, period, err := scheduler.ParseTemplate(t)
+	if err != nil {
+		s.SendErrorJSON(c, http.StatusBadRequest, "bad values in form", err)
+
+		return
+	}
+
+	freq, err := time.ParseDuration(f.Frequency)
+	if err != nil {
+		s.SendErrorJSON(c, http.StatusBadRequest, "bad value for frequency", err)
+
+		return
+	}
+
+	if err = validateMonitoringParams(period, freq); err != nil {
+		s.SendErrorJSON(c, http.StatusBadRequest, "bad values in form", err)
+
+		return
+	}
+
+	t.CurrencyID, err = s.getCurrencyIdByCurrencyCode(ctx, f.Currency)
+	if err != nil {
+		s.log.Error("can not get data currency data from db", field.Any("form", f), field.Error(err))
+		s.SendErrorJSON(c, http.StatusBadRequest, "can not get data currency data from db."+
+			" probably you try to create template for unsupported currency", err)
+
+		return
+	}
+
+	t.NextRunAt = schedule.Next(t.CreatedAt).UTC()
+
+	id, err := s.storage.Connector().Repo(t).Create(ctx, t)
+	if err != nil {
+		s.log.Error("can not create new template", field.Any("t", t), field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not create new template", err)
+
+		return
+	}
+
+	s.SendJSON(c, http.StatusOK, "Successfully created new template",
+		gin.H{
+			"TemplateID": id,
+			"NextRunAt":  t.NextRunAt,
+		})
+}
+
+// GetTemplates godoc
+// @Summary Get templates of recurring monitorings
+// @Description get all templates of recurring monitorings
+// @Id GetTemplates
+// @Tags Server API
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.HTTPGoodResponse
+// @Failure 500 {object} util.HTTPErrorResponse
+// @Router /api/v1/templates [get]
+func (s *Server) GetTemplates(c *gin.Context) {
+	ctx, cf := context.WithCancel(
+		helper.NewContextWithUUID(c.Copy().Request.Context(), c.GetString(mw.XRequestID)),
+	)
+	defer cf()
+
+	templates := make([]model.MonitoringTemplate, 0)
+	if err := s.storage.Connector().Repo(model.MonitoringTemplate{}).Select(ctx,
+		storage.Select("*").OrderBy("id"),
+		&templates,
+	); err != nil {
+		s.log.Error("can not get templates", field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not get templates", err)
+
+		return
+	}
+
+	s.SendJSON(c, http.StatusOK, "Templates of recurring monitorings (time in UTC)",
+		gin.H{
+			"Templates": templates,
+		})
+}
+
+// PostTemplatePause godoc
+// @Summary Pause template
+// @Description pause template, runs are not created while it is paused
+// @Id PostTemplatePause
+// @Tags Server API
+// @Param id path int true "id of template"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.HTTPGoodResponse
+// @Failure 400 {object} util.HTTPErrorResponse
+// @Failure 404 {object} util.HTTPErrorResponse
+// @Failure 500 {object} util.HTTPErrorResponse
+// @Router /api/v1/templates/{id}/pause [post]
+func (s *Server) PostTemplatePause(c *gin.Context) {
+	s.setTemplatePaused(c, true)
+}
+
+// PostTemplateResume godoc
+// @Summary Resume template
+// @Description resume paused template, next run is calculated from now (runs missed during pause are skipped)
+// @Id PostTemplateResume
+// @Tags Server API
+// @Param id path int true "id of template"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.HTTPGoodResponse
+// @Failure 400 {object} util.HTTPErrorResponse
+// @Failure 404 {object} util.HTTPErrorResponse
+// @Failure 500 {object} util.HTTPErrorResponse
+// @Router /api/v1/templates/{id}/resume [post]
+func (s *Server) PostTemplateResume(c *gin.Context) {
+	s.setTemplatePaused(c, false)
+}
+
+func (s *Server) setTemplatePaused(c *gin.Context, paused bool) {
+	ctx, cf := context.WithCancel(
+		helper.NewContextWithUUID(c.Copy().Request.Context(), c.GetString(mw.XRequestID)),
+	)
+	defer cf()
+
+	t, ok := s.bindAndGetTemplate(ctx, c)
+	if !ok {
+		return
+	}
+
+	set := map[string]any{"paused": paused}
+
+	if !paused {
+		schedule, _, err := scheduler.ParseTemplate(t)
+		if err != nil {
+			s.SendErrorJSON(c, http.StatusInternalServerError, "bad template in db", err)
+
+			return
+		}
+
+		t.NextRunAt = schedule.Next(time.Now().UTC()).UTC()
+		set["next_run_at"] = t.NextRunAt
+	}
+
+	if _, err := s.storage.Connector().Repo(t).UpdateCustom(ctx, set, storage.Eq{"id": t.ID}); err != nil {
+		s.log.Error("can not update template", field.ID(t.ID), field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not update template", err)
+
+		return
+	}
+
+	s.SendJSON(c, http.StatusOK, "Template has been updated",
+		gin.H{
+			"TemplateID": t.ID,
+			"Paused":     paused,
+			"NextRunAt":  t.NextRunAt,
+		})
+}
+
+// DeleteTemplate godoc
+// @Summary Delete template
+// @Description delete template, already created runs are kept
+// @Id DeleteTemplate
+// @Tags Server API
+// @Param id path int true "id of template"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.HTTPGoodResponse
+// @Failure 400 {object} util.HTTPErrorResponse
+// @Failure 404 {object} util.HTTPErrorResponse
+// @Failure 500 {object} util.HTTPErrorResponse
+// @Router /api/v1/templates/{id} [delete]
+func (s *Server) DeleteTemplate(c *gin.Context) {
+	ctx, cf := context.WithCancel(
+		helper.NewContextWithUUID(c.Copy().Request.Context(), c.GetString(mw.XRequestID)),
+	)
+	defer cf()
+
+	t, ok := s.bindAndGetTemplate(ctx, c)
+	if !ok {
+		return
+	}
+
+	if _, err := s.storage.Connector().Repo(t).Delete(ctx, t.ID); err != nil {
+		s.log.Error("can not delete template", field.ID(t.ID), field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not delete template", err)
+
+		return
+	}
+
+	s.SendJSON(c, http.StatusOK, "Template has been deleted",
+		gin.H{
+			"TemplateID": t.ID,
+		})
+}
+
+// GetTemplateRuns godoc
+// @Summary Get runs of template
+// @Description get monitorings created from template (last runs first)
+// @Id GetTemplateRuns
+// @Tags Server API
+// @Param id path int true "id of template"
+// @Param limit query int false "limit of runs"
+// @Accept  json
+// @Produce  json
+// @Success 200 {object} util.HTTPGoodResponse
+// @Failure 400 {object} util.HTTPErrorResponse
+// @Failure 404 {object} util.HTTPErrorResponse
+// @Failure 500 {object} util.HTTPErrorResponse
+// @Router /api/v1/templates/{id}/runs [get]
+func (s *Server) GetTemplateRuns(c *gin.Context) {
+	ctx, cf := context.WithCancel(
+		helper.NewContextWithUUID(c.Copy().Request.Context(), c.GetString(mw.XRequestID)),
+	)
+	defer cf()
+
+	t, ok := s.bindAndGetTemplate(ctx, c)
+	if !ok {
+		return
+	}
+
+	var f FormGetTemplateRuns
+	if err := c.ShouldBindQuery(&f); err != nil {
+		s.SendErrorJSON(c, http.StatusBadRequest, "can not parse params (query)", err)
+
+		return
+	}
+
+	if f.Limit == 0 {
+		f.Limit = defaultRunsLimit
+	}
+
+	runs := make([]model.Monitoring, 0, f.Limit)
+	if err := s.storage.Connector().Repo(model.Monitoring{}).Select(ctx,
+		storage.Select("*").Where(storage.Eq{"template_id": t.ID}).OrderBy("started_at DESC").Limit(f.Limit),
+		&runs,
+	); err != nil {
+		s.log.Error("can not get runs of template", field.ID(t.ID), field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not get runs of template", err)
+
+		return
+	}
+
+	s.SendJSON(c, http.StatusOK, "Runs of template (time in UTC)",
+		gin.H{
+			"TemplateID": t.ID,
+			"Runs":       runs,
+		})
+}
+
+// bindAndGetTemplate - bind id of template from uri and get template from db, send error response if failed.
+func (s *Server) bindAndGetTemplate(ctx context.Context, c *gin.Context) (model.MonitoringTemplate, bool) {
+	var (
+		f FormTemplateID
+		t model.MonitoringTemplate
+	)
+
+	if err := c.ShouldBindUri(&f); err != nil {
+		s.SendErrorJSON(c, http.StatusBadRequest, "can not parse params (uri)", err)
+
+		return t, false
+	}
+
+	if err := s.storage.Connector().Repo(t).Get(ctx, f.ID, &t); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			s.SendErrorJSON(c, http.StatusNotFound, "no template with that id", nil)
+
+			return t, false
+		}
+
+		s.log.Error("can not get template from db", field.ID(f.ID), field.Error(err))
+		s.SendErrorJSON(c, http.StatusInternalServerError, "can not get template from db", err)
+
+		return t, false
+	}
+
+	return t, true
+}
@@ -1,3 +1,171 @@
 package http
 
-// todo
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imperiuse/price_monitor/internal/services/storage"
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+func pricesEverySecond(from time.Time, cnt int) []model.Price {
+	prices := make([]model.Price, 0, cnt)
+	for i := 0; i < cnt; i++ {
+		prices = append(prices, model.Price{Time: from.Add(time.Duration(i) * time.Second), Price: float64(i)})
+	}
+
+	return prices
+}
+
+func Test_buildFreqSegments(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	segments, err := buildFreqSegments([]model.MonitoringChange{
+		{Frequency: "5s", EffectiveFrom: start.Add(time.Hour)},       // created
+		{Frequency: "5s", EffectiveFrom: start},                      // rescheduled earlier
+		{Frequency: "5s", EffectiveFrom: start.Add(time.Minute)},     // extended (same frequency)
+		{Frequency: "2s", EffectiveFrom: start.Add(2 * time.Minute)}, // frequency changed
+	}, start)
+	require.NoError(t, err)
+	assert.Equal(t, []freqSegment{
+		{from: start, freq: 5 * time.Second},
+		{from: start.Add(2 * time.Minute), freq: 2 * time.Second},
+	}, segments)
+
+	later := start.Add(time.Hour)
+
+	segments, err = buildFreqSegments([]model.MonitoringChange{
+		{Frequency: "5s", EffectiveFrom: start}, // created
+		{Frequency: "5s", EffectiveFrom: later}, // rescheduled later (same frequency)
+	}, later)
+	require.NoError(t, err)
+	assert.Equal(t, []freqSegment{{from: later, freq: 5 * time.Second}}, segments)
+
+	segments, err = buildFreqSegments([]model.MonitoringChange{
+		{Frequency: "5s", EffectiveFrom: start}, // created
+		{Frequency: "2s", EffectiveFrom: later}, // rescheduled later with new frequency
+	}, later)
+	require.NoError(t, err)
+	assert.Equal(t, []freqSegment{{from: later, freq: 2 * time.Second}}, segments)
+
+	_, err = buildFreqSegments([]model.MonitoringChange{{Frequency: "bad", EffectiveFrom: start}}, start)
+	assert.Error(t, err)
+}
+
+func Test_priceSegments(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(time.Hour)
+
+	assert.Equal(t, []storage.PriceSegment{
+		{From: start, To: start.Add(time.Minute), Freq: 5 * time.Second},
+		{From: start.Add(time.Minute), To: end, Freq: time.Second},
+	}, priceSegments([]freqSegment{
+		{from: start, freq: 5 * time.Second},
+		{from: start.Add(time.Minute), freq: time.Second},
+		{from: end.Add(time.Minute), freq: time.Minute}, // after finish of monitoring
+	}, end))
+}
+
+func Test_applyMonitoringPatch(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	scheduled := model.Monitoring{
+		Status:    model.StatusScheduled,
+		StartedAt: now.Add(time.Hour),
+		ExpiredAt: now.Add(2 * time.Hour),
+		Frequency: "5s",
+	}
+
+	m, status, err := applyMonitoringPatch(scheduled,
+		FormPatchMonitoring{StartAt: now.Add(3 * time.Hour), Extend: "30m", Frequency: "10s"}, now)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, now.Add(3*time.Hour), m.StartedAt)
+	assert.Equal(t, now.Add(4*time.Hour+30*time.Minute), m.ExpiredAt)
+	assert.Equal(t, "10s", m.Frequency)
+
+	_, status, err = applyMonitoringPatch(scheduled, FormPatchMonitoring{StartAt: now.Add(-time.Hour)}, now)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	_, status, err = applyMonitoringPatch(scheduled, FormPatchMonitoring{Extend: "24h"}, now)
+	assert.ErrorIs(t, err, errBadMonitoringParams)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	_, status, err = applyMonitoringPatch(scheduled, FormPatchMonitoring{Frequency: "100ms"}, now)
+	assert.ErrorIs(t, err, errBadMonitoringParams)
+	assert.Equal(t, http.StatusBadRequest, status)
+
+	running := scheduled
+	running.Status = model.StatusRunning
+
+	_, status, err = applyMonitoringPatch(running, FormPatchMonitoring{StartAt: now.Add(3 * time.Hour)}, now)
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusConflict, status)
+}
+
+func Test_monitoringStart(t *testing.T) {
+	now := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	start, err := monitoringStart(FormPostMonitoring{}, now)
+	require.NoError(t, err)
+	assert.Equal(t, now, start)
+
+	start, err = monitoringStart(FormPostMonitoring{StartIn: "10m"}, now)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(10*time.Minute), start)
+
+	at := time.Date(2022, 1, 1, 5, 0, 0, 0, time.FixedZone("UTC+3", 3*60*60))
+	start, err = monitoringStart(FormPostMonitoring{StartAt: at}, now)
+	require.NoError(t, err)
+	assert.Equal(t, at.UTC(), start)
+
+	start, err = monitoringStart(FormPostMonitoring{StartAt: now.Add(-time.Hour)}, now) // historical
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(-time.Hour), start)
+
+	_, err = monitoringStart(FormPostMonitoring{StartIn: "-1m"}, now)
+	assert.Error(t, err)
+
+	_, err = monitoringStart(FormPostMonitoring{StartAt: at, StartIn: "1m"}, now)
+	assert.Error(t, err)
+}
+
+// pricesSlotsCounter - in memory slotsCounter over prices.
+func pricesSlotsCounter(prices []model.Price) slotsCounter {
+	return func(from, to time.Time, freq time.Duration) (int, error) {
+		slots := map[time.Duration]struct{}{}
+
+		for _, p := range prices {
+			if !p.Time.Before(from) && p.Time.Before(to) {
+				slots[p.Time.Sub(from)/freq] = struct{}{}
+			}
+		}
+
+		return len(slots), nil
+	}
+}
+
+func Test_calcCoverage(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	collected := pricesSlotsCounter(append(pricesEverySecond(start, 10), pricesEverySecond(start.Add(20*time.Second), 10)...))
+
+	r, err := calcCoverage([]freqSegment{{from: start, freq: 5 * time.Second}}, start.Add(30*time.Second), collected)
+	require.NoError(t, err)
+	assert.Equal(t, ResponseCoverage{Expected: 6, Collected: 4, Ratio: 4.0 / 6}, r)
+
+	r, err = calcCoverage([]freqSegment{
+		{from: start, freq: 10 * time.Second},
+		{from: start.Add(10 * time.Second), freq: 4 * time.Second},
+	}, start.Add(30*time.Second), collected)
+	require.NoError(t, err)
+	assert.Equal(t, ResponseCoverage{Expected: 6, Collected: 4, Ratio: 4.0 / 6}, r) // 1 of 1 slot + 3 of 5 slots
+
+	r, err = calcCoverage([]freqSegment{{from: start, freq: 10 * time.Second}}, start.Add(25*time.Second),
+		pricesSlotsCounter(nil))
+	require.NoError(t, err)
+	assert.Equal(t, ResponseCoverage{Expected: 3}, r)
+}
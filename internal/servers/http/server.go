@@ -2,6 +2,7 @@ package http
 
 import (
 	"context"
+	"crypto/subtle"
 	"errors"
 	"fmt"
 	"net"
@@ -18,7 +19,9 @@ import (
 	"github.com/imperiuse/price_monitor/internal/logger"
 	"github.com/imperiuse/price_monitor/internal/logger/field"
 	mw "github.com/imperiuse/price_monitor/internal/servers/http/middlerware"
+	"github.com/imperiuse/price_monitor/internal/services/controllers/general/monitor"
 	"github.com/imperiuse/price_monitor/internal/services/storage"
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
 	"go.uber.org/zap"
 )
 
@@ -30,6 +33,7 @@ type (
 		Address     string
 		DomainName  string `yaml:"domainName"`
 		AllowOrigin string `yaml:"allowOrigin"`
+		AdminToken  string `yaml:"adminToken"` // bearer token of admin api, "" - admin api is disabled
 
 		Timeouts Timeouts `yaml:"timeouts"`
 	}
@@ -43,6 +47,28 @@ type (
 		writeTimeout time.Duration
 	}
 
+	// ClusterManager - source of info about cluster state and readiness of node, also it allows to hand leadership over.
+	ClusterManager interface {
+		ClusterStatus(ctx context.Context) (monitor.ClusterStatus, error)
+		Handover(ctx context.Context) error
+		Ready() error
+	}
+
+	// AlertRuleValidator - validates alert rules before they are stored (e.g. channels of rule must be configured).
+	AlertRuleValidator interface {
+		ValidateRule(r model.AlertRule) error
+	}
+
+	// CallbackURLValidator - validates callback urls of monitorings (e.g. url must not point to internal services).
+	CallbackURLValidator interface {
+		ValidateURL(ctx context.Context, rawURL string) error
+	}
+
+	// MonitoringWaiter - signals finish of monitorings to requests waiting for their results (long polling).
+	MonitoringWaiter interface {
+		Subscribe(id model.Identity) (<-chan struct{}, func())
+	}
+
 	// Server - server struct
 	Server struct {
 		config    Config
@@ -50,6 +76,11 @@ type (
 		server    *http.Server
 		ginEngine *gin.Engine
 		storage   storage.Storage
+		cluster   ClusterManager
+		alerts    AlertRuleValidator
+		callbacks CallbackURLValidator
+		waiter    MonitoringWaiter
+		maxWait   time.Duration // max wait of monitoring finish, response must be written before write timeout
 	}
 )
 
@@ -62,6 +93,8 @@ const (
 	apiPathVersion1 = apiPath + "/v1"
 
 	apiPathVersion = apiPathVersion1
+
+	adminPath = "/admin"
 )
 
 // New - create new http server.
@@ -70,6 +103,10 @@ func New(
 	config Config,
 	logger *logger.Logger,
 	storage storage.Storage,
+	cluster ClusterManager,
+	alertRules AlertRuleValidator,
+	callbacks CallbackURLValidator,
+	waiter MonitoringWaiter,
 ) (
 	*Server,
 	error,
@@ -110,6 +147,15 @@ func New(
 		},
 		ginEngine: e,
 		storage:   storage,
+		cluster:   cluster,
+		alerts:    alertRules,
+		callbacks: callbacks,
+		waiter:    waiter,
+		maxWait:   maxMonitoringWait,
+	}
+
+	if limit := config.Timeouts.writeTimeout - waitWriteReserve; limit < s.maxWait {
+		s.maxWait = limit
 	}
 
 	s.log.Info("starting create routes for gin s")
@@ -147,10 +193,61 @@ func New(
 
 	monitroing.GET(":id", s.GetMonitoring)
 	monitroing.POST("", s.PostMonitoring)
+	monitroing.PATCH(":id", s.PatchMonitoring)
+	monitroing.DELETE(":id", s.DeleteMonitoring)
+	monitroing.POST(":id/finish", s.PostMonitoringFinish)
+
+	apiVer.GET("/monitorings", s.GetMonitorings)
+
+	templates := apiVer.Group("/templates")
+
+	templates.GET("", s.GetTemplates)
+	templates.POST("", s.PostTemplate)
+	templates.DELETE(":id", s.DeleteTemplate)
+	templates.POST(":id/pause", s.PostTemplatePause)
+	templates.POST(":id/resume", s.PostTemplateResume)
+	templates.GET(":id/runs", s.GetTemplateRuns)
+
+	prices := apiVer.Group("/prices")
+
+	prices.GET(":currency", s.GetPrices)
+	prices.GET(":currency/candles", s.GetCandles)
+
+	alerts := apiVer.Group("/alerts")
+
+	alerts.GET("", s.GetAlertRules)
+	alerts.POST("", s.PostAlertRule)
+	alerts.DELETE(":id", s.DeleteAlertRule)
+	alerts.GET("events", s.GetAlertEvents)
+
+	apiVer.GET("/cluster", s.GetCluster)
+
+	if config.AdminToken == "" {
+		s.log.Warn("admin token is not set, admin api is disabled")
+
+		return s, nil
+	}
+
+	admin := e.Group(adminPath, s.adminAuth)
+
+	admin.POST("/leadership/release", s.PostLeadershipRelease)
 
 	return s, nil
 }
 
+// adminAuth - check bearer token of admin api request.
+func (s *Server) adminAuth(c *gin.Context) {
+	token := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.config.AdminToken)) != 1 {
+		s.SendErrorJSON(c, http.StatusUnauthorized, "bad admin token", nil)
+
+		return
+	}
+
+	c.Next()
+}
+
 // Run -run http server.
 func (s *Server) Run() {
 	go func() {
@@ -172,19 +269,26 @@ func (s *Server) Stop(ctx context.Context) {
 }
 
 // GetConsulServiceRegistration - GetConsulServiceRegistration.
+// All nodes are registered under one service name (so peers can be found by it), ID of service is uniq per node.
 func (s *Server) GetConsulServiceRegistration(cc consul.Config) *consul.Service {
 	httpHost, httpPort, _ := net.SplitHostPort(s.config.Address)
 
 	port, _ := strconv.Atoi(httpPort)
 
+	outboundIP := helper.GetOutboundIP(cc.DNS...).String()
+	if httpHost == "" {
+		httpHost = outboundIP
+	}
+
 	return &consul.Service{
-		ID:      fmt.Sprintf("%s_%s_%s_%s", s.config.Name, httpHost, httpPort, strings.Join(cc.Tags, "_")),
+		ID:      fmt.Sprintf("%s_%s_%s_%s", s.config.Name, s.config.NodeID, httpPort, strings.Join(cc.Tags, "_")),
 		Name:    s.config.Name,
 		Address: httpHost,
 		Port:    port,
 		Tags:    cc.Tags,
+		Meta:    map[string]string{consul.MetaNodeID: s.config.NodeID},
 		Check: &consul.ServiceCheck{
-			HTTP:     fmt.Sprintf("http://%s:%d/health", helper.GetOutboundIP(cc.DNS...), port),
+			HTTP:     fmt.Sprintf("http://%s:%d/health", outboundIP, port),
 			Interval: cc.Interval.String(),
 			Timeout:  cc.Timeout.String(),
 		},
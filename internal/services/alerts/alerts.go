@@ -0,0 +1,249 @@
+// Package alerts - package for alert rules on prices: evaluation of rules on new samples and notifications
+package alerts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/imperiuse/price_monitor/internal/logger"
+	"github.com/imperiuse/price_monitor/internal/logger/field"
+	"github.com/imperiuse/price_monitor/internal/services/storage"
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+const maxChangeWindow = 24 * time.Hour
+
+// ErrBadRule - alert rule has bad values.
+var ErrBadRule = errors.New("bad alert rule")
+
+// Evaluator - evaluates alert rules of currency on every new sample of its price. Rule fires once when its condition
+// becomes true, then it's disarmed until price goes back beyond threshold by hysteresis (re-armed). Fires are not
+// more often than cooldown of rule (crossing in cooldown is skipped). State of rules is stored in db.
+type Evaluator struct {
+	log           *logger.Logger
+	storage       storage.Storage
+	notifiers     map[string]Notifier
+	timeoutNotify time.Duration
+}
+
+// New - constructor of alerts Evaluator.
+func New(cfg Config, l *logger.Logger, s storage.Storage) (*Evaluator, error) {
+	timeoutNotify, err := time.ParseDuration(cfg.TimeoutNotify)
+	if err != nil {
+		return nil, fmt.Errorf("alerts: can't parse time.ParseDuration(cfg.TimeoutNotify): %w", err)
+	}
+
+	e := &Evaluator{
+		log:           l,
+		storage:       s,
+		notifiers:     make(map[string]Notifier, len(cfg.Channels)),
+		timeoutNotify: timeoutNotify,
+	}
+
+	for name, ch := range cfg.Channels {
+		if e.notifiers[name], err = newNotifier(ch, l); err != nil {
+			return nil, fmt.Errorf("alerts: channel %q: %w", name, err)
+		}
+	}
+
+	return e, nil
+}
+
+// ValidateRule - validate values of rule, channels of rule must be configured.
+func (e *Evaluator) ValidateRule(r model.AlertRule) error {
+	switch r.Kind {
+	case model.AlertCrossAbove, model.AlertCrossBelow:
+		if r.Window != "" {
+			return fmt.Errorf("%w: window is only for %s rule", ErrBadRule, model.AlertChange)
+		}
+	case model.AlertChange:
+		if r.Threshold > 100 { //nolint gomnd // percents
+			return fmt.Errorf("%w: threshold of %s rule is percent in (0, 100]", ErrBadRule, model.AlertChange)
+		}
+
+		window, err := time.ParseDuration(r.Window)
+		if err != nil || window <= 0 || window > maxChangeWindow {
+			return fmt.Errorf("%w: window must be in (0, %s]: %q", ErrBadRule, maxChangeWindow, r.Window)
+		}
+	default:
+		return fmt.Errorf("%w: unknown kind %q", ErrBadRule, r.Kind)
+	}
+
+	if r.Threshold <= 0 || r.Hysteresis < 0 {
+		return fmt.Errorf("%w: threshold must be positive, hysteresis must not be negative", ErrBadRule)
+	}
+
+	if cooldown, err := time.ParseDuration(r.Cooldown); err != nil || cooldown < 0 {
+		return fmt.Errorf("%w: bad cooldown %q", ErrBadRule, r.Cooldown)
+	}
+
+	if len(r.Channels) == 0 {
+		return fmt.Errorf("%w: no channels", ErrBadRule)
+	}
+
+	for _, ch := range r.Channels {
+		if _, ok := e.notifiers[ch]; !ok {
+			return fmt.Errorf("%w: unknown channel %q", ErrBadRule, ch)
+		}
+	}
+
+	return nil
+}
+
+// Observe - evaluate rules of currency on new sample of its price.
+func (e *Evaluator) Observe(ctx context.Context, currency model.CurrencyCode, t time.Time, price float64) error {
+	rules, err := storage.AlertRulesOf(ctx, e.storage, currency)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+
+	// nolint rangeValCopy
+	for _, r := range rules {
+		if err = e.evaluate(ctx, currency, r, t, price); err != nil {
+			e.log.Error("[Alerts] err while evaluate rule", field.ID(r.ID), field.Error(err))
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+func (e *Evaluator) evaluate(
+	ctx context.Context,
+	currency model.CurrencyCode,
+	r model.AlertRule,
+	t time.Time,
+	price float64,
+) error {
+	cooldown, err := time.ParseDuration(r.Cooldown)
+	if err != nil {
+		return fmt.Errorf("bad cooldown of rule: %w", err)
+	}
+
+	value := price
+
+	if r.Kind == model.AlertChange {
+		window, err := time.ParseDuration(r.Window)
+		if err != nil {
+			return fmt.Errorf("bad window of rule: %w", err)
+		}
+
+		lo, hi, ok, err := storage.PriceRange(ctx, e.storage, currency, t.Add(-window), t)
+		if err != nil || !ok {
+			return err
+		}
+
+		value = moveOf(price, lo, hi)
+	}
+
+	armed, fire := decide(r, value, t, cooldown)
+
+	if !fire {
+		if r.Armed == nil || *r.Armed != armed {
+			_, err = storage.SetAlertRuleArmed(ctx, e.storage, r.ID, r.Armed, armed)
+		}
+
+		return err
+	}
+
+	event := model.AlertEvent{
+		RuleID:     &r.ID,
+		CurrencyID: r.CurrencyID,
+		FiredAt:    t,
+		Price:      price,
+		Value:      value,
+		Message:    message(currency, r, value),
+	}
+
+	if event.ID, err = storage.FireAlert(ctx, e.storage, event); err != nil || event.ID == 0 {
+		return err
+	}
+
+	e.notify(Notification{Currency: currency, Rule: r, Event: event})
+
+	return nil
+}
+
+// notify - send notification to channels of rule (in background, scanning is not blocked).
+func (e *Evaluator) notify(n Notification) {
+	for _, ch := range n.Rule.Channels {
+		notifier, ok := e.notifiers[ch]
+		if !ok {
+			e.log.Error("[Alerts] unknown channel of rule", field.ID(n.Rule.ID), field.String("channel", ch))
+
+			continue
+		}
+
+		go func(ch string) {
+			ctx, cancel := context.WithTimeout(context.Background(), e.timeoutNotify)
+			defer cancel()
+
+			if err := notifier.Notify(ctx, n); err != nil {
+				e.log.Error("[Alerts] err while notify", field.ID(n.Event.ID), field.String("channel", ch),
+					field.Error(err))
+			}
+		}(ch)
+	}
+}
+
+// decide - new armed state of rule and does it fire for value (price for cross rules, percent of move for change
+// rule). Not evaluated yet rule is only armed or disarmed, so it fires on the next crossing, not immediately.
+func decide(r model.AlertRule, value float64, at time.Time, cooldown time.Duration) (bool, bool) {
+	var triggered, rearmed bool
+
+	switch r.Kind {
+	case model.AlertCrossAbove:
+		triggered, rearmed = value >= r.Threshold, value < r.Threshold-r.Hysteresis
+	case model.AlertCrossBelow:
+		triggered, rearmed = value <= r.Threshold, value > r.Threshold+r.Hysteresis
+	case model.AlertChange:
+		triggered, rearmed = value >= r.Threshold, value < r.Threshold-r.Hysteresis
+	}
+
+	switch {
+	case r.Armed == nil:
+		return !triggered, false
+	case !*r.Armed:
+		return rearmed, false
+	case !triggered:
+		return true, false
+	}
+
+	inCooldown := r.LastFiredAt != nil && at.Before(r.LastFiredAt.Add(cooldown))
+
+	return false, !inCooldown
+}
+
+// moveOf - max move of price in percents from min (up) or from max (down) price of window.
+func moveOf(price, lo, hi float64) float64 {
+	var up, down float64
+
+	if lo > 0 {
+		up = (price - lo) / lo * 100 //nolint gomnd // percents
+	}
+
+	if hi > 0 {
+		down = (hi - price) / hi * 100 //nolint gomnd // percents
+	}
+
+	if up > down {
+		return up
+	}
+
+	return down
+}
+
+func message(currency model.CurrencyCode, r model.AlertRule, value float64) string {
+	switch r.Kind {
+	case model.AlertCrossAbove:
+		return fmt.Sprintf("%s crossed above %g: %g", currency, r.Threshold, value)
+	case model.AlertCrossBelow:
+		return fmt.Sprintf("%s crossed below %g: %g", currency, r.Threshold, value)
+	default:
+		return fmt.Sprintf("%s moved %.2f%% within %s (more than %g%%)", currency, value, r.Window, r.Threshold)
+	}
+}
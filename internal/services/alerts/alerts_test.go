@@ -0,0 +1,111 @@
+package alerts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imperiuse/price_monitor/internal/logger"
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+func Test_decide(t *testing.T) {
+	now := time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC)
+	yes, no := true, false
+
+	rule := model.AlertRule{Kind: model.AlertCrossAbove, Threshold: 45000, Hysteresis: 100}
+
+	// run of prices: state of rule is stored after every price
+	type step struct {
+		price       float64
+		armed, fire bool
+	}
+
+	steps := []step{
+		{45500, false, false}, // not evaluated rule is only disarmed (price is above already)
+		{44950, false, false}, // below threshold, but within hysteresis
+		{44800, true, false},  // re-armed
+		{45000, false, true},  // crossed
+		{45100, false, false}, // still above, fired once
+	}
+
+	var armed *bool
+
+	for i, s := range steps {
+		rule.Armed = armed
+
+		a, fire := decide(rule, s.price, now, 0)
+		assert.Equal(t, s.armed, a, i)
+		assert.Equal(t, s.fire, fire, i)
+
+		armed = &a
+	}
+
+	below := model.AlertRule{Kind: model.AlertCrossBelow, Threshold: 40000, Armed: &yes}
+	_, fire := decide(below, 40001, now, 0)
+	assert.False(t, fire)
+	a, fire := decide(below, 39999, now, 0)
+	assert.False(t, a)
+	assert.True(t, fire)
+
+	below.Armed = &no
+	a, _ = decide(below, 40001, now, 0)
+	assert.True(t, a)
+
+	// cooldown: crossing is skipped and rule is disarmed
+	lastFiredAt := now.Add(-time.Minute)
+	change := model.AlertRule{Kind: model.AlertChange, Threshold: 3, Armed: &yes, LastFiredAt: &lastFiredAt}
+
+	a, fire = decide(change, 3.5, now, 5*time.Minute)
+	assert.False(t, a)
+	assert.False(t, fire)
+
+	_, fire = decide(change, 3.5, now, time.Minute)
+	assert.True(t, fire)
+}
+
+func Test_moveOf(t *testing.T) {
+	assert.InDelta(t, 5, moveOf(105, 100, 105), 1e-9) // up from min
+	assert.InDelta(t, 10, moveOf(90, 90, 100), 1e-9)  // down from max
+	assert.InDelta(t, 0, moveOf(100, 100, 100), 1e-9) // flat
+	assert.InDelta(t, 25, moveOf(100, 80, 100), 1e-9) // up is bigger than down
+	assert.InDelta(t, 20, moveOf(80, 80, 100), 1e-9)  // down is bigger than up
+	assert.InDelta(t, 0, moveOf(0, 0, 0), 1e-9)       // no prices
+}
+
+func TestEvaluator_ValidateRule(t *testing.T) {
+	e, err := New(Config{
+		TimeoutNotify: "1s",
+		Channels:      map[string]ChannelConfig{"log": {Type: ChannelLog}},
+	}, logger.NewNop(), nil)
+	require.NoError(t, err)
+
+	good := []model.AlertRule{
+		{Kind: model.AlertCrossAbove, Threshold: 45000, Cooldown: "0s", Channels: model.Channels{"log"}},
+		{Kind: model.AlertChange, Threshold: 3, Window: "10m", Hysteresis: 1, Cooldown: "5m",
+			Channels: model.Channels{"log"}},
+	}
+	for _, r := range good {
+		assert.NoError(t, e.ValidateRule(r))
+	}
+
+	bad := []model.AlertRule{
+		{Kind: "cross", Threshold: 45000, Cooldown: "0s", Channels: model.Channels{"log"}},
+		{Kind: model.AlertCrossAbove, Threshold: 45000, Window: "10m", Cooldown: "0s", Channels: model.Channels{"log"}},
+		{Kind: model.AlertChange, Threshold: 3, Cooldown: "0s", Channels: model.Channels{"log"}},
+		{Kind: model.AlertChange, Threshold: 300, Window: "10m", Cooldown: "0s", Channels: model.Channels{"log"}},
+		{Kind: model.AlertCrossBelow, Threshold: 45000, Hysteresis: -1, Cooldown: "0s", Channels: model.Channels{"log"}},
+		{Kind: model.AlertCrossBelow, Threshold: 45000, Cooldown: "soon", Channels: model.Channels{"log"}},
+		{Kind: model.AlertCrossBelow, Threshold: 45000, Cooldown: "0s"},
+		{Kind: model.AlertCrossBelow, Threshold: 45000, Cooldown: "0s", Channels: model.Channels{"slack"}},
+	}
+	for i, r := range bad {
+		assert.ErrorIs(t, e.ValidateRule(r), ErrBadRule, i)
+	}
+
+	_, err = New(Config{TimeoutNotify: "1s", Channels: map[string]ChannelConfig{"ops": {Type: ChannelWebhook}}},
+		logger.NewNop(), nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,21 @@
+package alerts
+
+type (
+	// Config - config of alerts.
+	Config struct {
+		// TimeoutNotify - timeout of one notification
+		TimeoutNotify string `yaml:"timeoutNotify"`
+
+		// Channels - notification channels by name, rules refer to them
+		Channels map[string]ChannelConfig `yaml:"channels"`
+	}
+
+	// ChannelConfig - config of notification channel.
+	ChannelConfig struct {
+		// Type - "log" (alert is written to log) or "webhook" (json of alert is posted to URL)
+		Type string `yaml:"type"`
+
+		// URL - url of webhook
+		URL string `yaml:"url"`
+	}
+)
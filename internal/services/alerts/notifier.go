@@ -0,0 +1,92 @@
+package alerts
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/imperiuse/price_monitor/internal/logger"
+	"github.com/imperiuse/price_monitor/internal/logger/field"
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+// Types of notification channels.
+const (
+	ChannelLog     = "log"
+	ChannelWebhook = "webhook"
+)
+
+type (
+	// Notification - fired alert with its rule.
+	Notification struct {
+		Currency model.CurrencyCode `json:"currency"`
+		Rule     model.AlertRule    `json:"rule"`
+		Event    model.AlertEvent   `json:"event"`
+	}
+
+	// Notifier - notification channel.
+	Notifier interface {
+		Notify(ctx context.Context, n Notification) error
+	}
+
+	logNotifier struct {
+		log *logger.Logger
+	}
+
+	webhookNotifier struct {
+		url    string
+		client *http.Client
+	}
+)
+
+func newNotifier(cfg ChannelConfig, l *logger.Logger) (Notifier, error) {
+	switch cfg.Type {
+	case ChannelLog:
+		return &logNotifier{log: l}, nil
+	case ChannelWebhook:
+		if cfg.URL == "" {
+			return nil, fmt.Errorf("url of webhook is empty")
+		}
+
+		return &webhookNotifier{url: cfg.URL, client: &http.Client{}}, nil
+	default:
+		return nil, fmt.Errorf("unknown type of channel %q", cfg.Type)
+	}
+}
+
+// Notify - write alert to log.
+func (n *logNotifier) Notify(_ context.Context, a Notification) error {
+	n.log.Warn("[Alerts] "+a.Event.Message, field.ID(a.Rule.ID), field.String("currency", a.Currency),
+		field.Any("price", a.Event.Price), field.Any("firedAt", a.Event.FiredAt))
+
+	return nil
+}
+
+// Notify - post json of alert to webhook.
+func (n *webhookNotifier) Notify(ctx context.Context, a Notification) error {
+	body, err := json.Marshal(a)
+	if err != nil {
+		return fmt.Errorf("can not marshal notification: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("can not create request to webhook: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("can not post to webhook: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
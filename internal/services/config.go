@@ -3,6 +3,7 @@ package services
 import (
 	"go.uber.org/fx"
 
+	"github.com/imperiuse/price_monitor/internal/services/alerts"
 	"github.com/imperiuse/price_monitor/internal/services/controllers"
 	"github.com/imperiuse/price_monitor/internal/services/storage"
 )
@@ -13,4 +14,5 @@ type Config struct {
 
 	Controllers controllers.Config `yaml:"controllers"`
 	Storage     storage.Config     `yaml:"storage"`
+	Alerts      alerts.Config      `yaml:"alerts"`
 }
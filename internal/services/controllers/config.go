@@ -11,4 +11,26 @@ type Config struct {
 	Slave   slave.Config
 	General general.Config
 	Master  master.Config
+
+	// Enabled - enable flags of controllers by controller name (controller which is absent here is enabled)
+	Enabled map[string]bool `yaml:"enabled"`
+
+	// Supervisor - restart policy of supervised goroutines of all controllers
+	Supervisor struct {
+		// MaxRestarts - max cnt of restarts in a row (0 - never restart, -1 - forever)
+		MaxRestarts *int `yaml:"maxRestarts"`
+
+		// MinBackoff - delay before first restart (doubled for every next restart in a row)
+		MinBackoff string `yaml:"minBackoff"`
+
+		// MaxBackoff - max delay before restart
+		MaxBackoff string `yaml:"maxBackoff"`
+	} `yaml:"supervisor"`
+}
+
+// IsEnabled - is controller with name enabled.
+func (c Config) IsEnabled(name string) bool {
+	enabled, found := c.Enabled[name]
+
+	return !found || enabled
 }
@@ -2,6 +2,8 @@ package controllers
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/imperiuse/price_monitor/internal/logger"
@@ -12,9 +14,32 @@ import (
 type DaemonController interface {
 	Run(ctx context.Context) error
 	Shutdown(ctx context.Context)
+	Status() Status
 }
 
+// Flusher - optional interface of DaemonController, which can finish all in-flight work without stopping.
+type Flusher interface {
+	Flush(ctx context.Context) error
+}
+
+// States of controllers.
+const (
+	StateStarting State = "starting"
+	StateRunning  State = "running"
+	StateDegraded State = "degraded" // running, but some supervised goroutines failed or controller reported bad health
+	StateStopped  State = "stopped"
+)
+
 type (
+	// State - running state of controller.
+	State string
+
+	// Status - status of controller.
+	Status struct {
+		Name   string `json:"name"`
+		State  State  `json:"state"`
+		Health string `json:"health,omitempty"` // description of health problem ("" - healthy)
+	}
 
 	// Base - base daemon controller ("parent" for all controllers).
 	Base struct {
@@ -23,6 +48,12 @@ type (
 
 		handlers     []Handler
 		shutdownFunc ShutdownFunc
+
+		mu            sync.RWMutex // protect fields below
+		state         State
+		health        error            // health reported by controller
+		failedWorkers map[string]error // supervised goroutines, which are failed now (name -> last error)
+		restartPolicy RestartPolicy
 	}
 
 	// Handler - handlers for broker events.
@@ -38,6 +69,11 @@ func New(name string, log *logger.Logger) *Base {
 		Log:          log.With(field.Controller(name)),
 		handlers:     nil,                          // no handlers. Yes, it safety for usage in range loop
 		shutdownFunc: func(ctx context.Context) {}, // do nothing
+
+		state:         StateStopped,
+		health:        nil,
+		failedWorkers: map[string]error{},
+		restartPolicy: DefaultRestartPolicy,
 	}
 }
 
@@ -51,6 +87,53 @@ func (b *Base) RegisterShutdownFunc(f ShutdownFunc) {
 	b.shutdownFunc = f
 }
 
+// SetState - set lifecycle state of controller (starting, running or stopped, degraded is calculated by Status).
+func (b *Base) SetState(s State) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.state = s
+
+	if s == StateStopped {
+		b.health = nil
+		b.failedWorkers = map[string]error{}
+	}
+}
+
+// ReportHealth - report health of controller (nil - healthy).
+func (b *Base) ReportHealth(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.health = err
+}
+
+// Status - return status of controller.
+func (b *Base) Status() Status {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	st := Status{Name: b.Name, State: b.state}
+
+	if b.state != StateRunning {
+		return st
+	}
+
+	if b.health != nil {
+		st.State, st.Health = StateDegraded, b.health.Error()
+
+		return st
+	}
+
+	for worker, err := range b.failedWorkers {
+		st.State, st.Health = StateDegraded, fmt.Sprintf("worker %s failed: %s", worker, err)
+
+		break
+	}
+
+	return st
+}
+
 // Run - default runner for controllers.
 func (b *Base) Run(ctx context.Context) error {
 	for _, h := range b.handlers {
@@ -60,6 +143,8 @@ func (b *Base) Run(ctx context.Context) error {
 		}
 	}
 
+	b.SetState(StateRunning)
+
 	// nolint, that's ok, ctx is already Done, it's dead, need new ctx
 	go func(ctx context.Context) {
 		<-ctx.Done()
@@ -77,4 +162,6 @@ func (b *Base) Run(ctx context.Context) error {
 // Shutdown - runs shutdown func register for direct controller.
 func (b *Base) Shutdown(ctx context.Context) {
 	b.shutdownFunc(ctx)
+
+	b.SetState(StateStopped)
 }
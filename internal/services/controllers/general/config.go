@@ -3,7 +3,20 @@ package general
 // Config - config for all master controllers.
 type Config struct {
 	Monitor struct {
-		// timeoutConsulLeaderCheck - таймаут чека кто лидер через consul kv, (попытка забрать лидерство, если нет лидера)
+		// TimeoutConsulLeaderCheck - retry timeout of consul queries if consul is unavailable
+		// (leadership itself is watched by consul blocking queries on leader key)
 		TimeoutConsulLeaderCheck string `yaml:"timeoutConsulLeaderCheck"`
+
+		// HandoverTimeout - max time of waiting successor confirmation on leadership handover,
+		// also node does not try become a leader during this time after handover
+		HandoverTimeout string `yaml:"handoverTimeout"`
 	} `yaml:"monitor"`
+
+	Sharder struct {
+		// VirtualNodes - cnt of virtual nodes per node in consistent hashing ring
+		VirtualNodes int `yaml:"virtualNodes"`
+
+		// IntervalRebalance - interval of retry to acquire shard locks, which are still held by previous owners
+		IntervalRebalance string `yaml:"intervalRebalance"`
+	} `yaml:"sharder"`
 }
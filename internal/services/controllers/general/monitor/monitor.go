@@ -2,42 +2,80 @@ package monitor
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/imperiuse/price_monitor/internal/consul"
 	"github.com/imperiuse/price_monitor/internal/logger"
 	"github.com/imperiuse/price_monitor/internal/logger/field"
 	"github.com/imperiuse/price_monitor/internal/services/controllers"
+	"github.com/imperiuse/price_monitor/internal/services/controllers/leadership"
 	"github.com/imperiuse/price_monitor/internal/services/storage"
 )
 
 const controllerMasterLockKey = "pm/services/controllers/master/master_lock_key"
 
+var (
+	// ErrNotMaster - node is not a master.
+	ErrNotMaster = errors.New("node is not a master")
+	// ErrNotReady - node is not ready to serve.
+	ErrNotReady = errors.New("node is not ready")
+)
+
 type (
 
 	// config - config for master-monitor controller.
 	config struct {
-		timeoutConsulLeaderCheck time.Duration
+		timeoutConsulLeaderCheck time.Duration // retry timeout of failed consul queries
+		handoverTimeout          time.Duration // max time of waiting successor confirmation, also cooldown after handover
 		shutdownCtxTimeout       time.Duration
 
 		appVersion string
 	}
 
-	// Controller - struct which check is it app(node) master or not, also,
-	// it run or shutdown all other master-controllers.
+	// Controller - struct which watch is it app(node) master or not, also,
+	// it run or shutdown all other master-controllers and emits leadership events.
 	Controller struct {
 		*controllers.Base
 
 		config  config
 		consul  *consul.Client
 		storage storage.Storage
+		bus     *leadership.Bus
+
+		controllers Controllers
+		runCtx      context.Context // ctx of Run, controllers are run with it
 
-		masterControllers []controllers.DaemonController
+		// transitionMu - serialize changes of master flag between watch goroutine and leadership handover
+		transitionMu  sync.Mutex
+		stepDownUntil time.Time // do not try become a master until this time (after handover)
+		handovers     uint64    // number of handovers (atomic), states of leader key read before handover are stale
 
+		mu                    sync.RWMutex // protect isMaster and leader for readers outside of watch goroutine
 		isMaster              bool
+		leader                consul.LeaderInfo
 		allControllersStarted bool
 	}
+
+	// Controllers - controllers which are run by monitor depending on role of node.
+	Controllers struct {
+		General []controllers.DaemonController // run on every node
+		Master  []controllers.DaemonController // run only on master node
+		Slave   []controllers.DaemonController // run only on not master (follower) node
+	}
+
+	// ClusterStatus - status of cluster from the point of view of this node.
+	ClusterStatus struct {
+		NodeID        string               `json:"node_id"`
+		IsLeader      bool                 `json:"is_leader"`
+		Leader        consul.LeaderInfo    `json:"leader"`
+		Nodes         []string             `json:"nodes"` // addresses of healthy nodes
+		LastLeaderAck time.Time            `json:"last_leader_ack"`
+		Controllers   []controllers.Status `json:"controllers"`
+	}
 )
 
 const name = "monitor"
@@ -50,20 +88,31 @@ func New(
 	logger *logger.Logger,
 	consul *consul.Client,
 	storage storage.Storage,
-	masterControllers ...controllers.DaemonController,
+	bus *leadership.Bus,
+	groups controllers.Groups,
 ) (*Controller, error) {
 	c := &Controller{
 		Base:                  controllers.New(name, logger),
 		config:                config{appVersion: appVersion},
 		consul:                consul,
 		storage:               storage,
-		masterControllers:     masterControllers,
+		bus:                   bus,
 		isMaster:              false,
 		allControllersStarted: false,
 	}
 
+	c.controllers = Controllers{
+		General: c.enabledControllers(cfg, groups.General),
+		Master:  c.enabledControllers(cfg, groups.Master),
+		Slave:   c.enabledControllers(cfg, groups.Slave),
+	}
+
 	c.Base.RegisterShutdownFunc(
-		func(ctx context.Context) { c.shutdownAllMasterControllers() },
+		func(ctx context.Context) {
+			c.shutdownControllers(c.controllers.Master)
+			c.shutdownControllers(c.controllers.Slave)
+			c.shutdownControllers(c.controllers.General)
+		},
 	)
 
 	return c, c.parseConfig(cfg)
@@ -71,43 +120,313 @@ func New(
 
 // Run - start monitor controller.
 func (c *Controller) Run(ctx context.Context) error {
-	c.checkTrySetMasterFlag(ctx)
+	if _, err := c.consul.EnsureSession(); err != nil {
+		return fmt.Errorf("[Monitor] can't create consul session: %w", err)
+	}
+
+	c.SetState(controllers.StateStarting)
 
-	go func(ctx context.Context) {
-		c.Log.Info("[Monitor] Run")
-		defer c.Log.Info("[Monitor] Finished")
+	c.runCtx = ctx
 
-		tConsulCoreLeader := time.NewTicker(c.config.timeoutConsulLeaderCheck)
-		defer tConsulCoreLeader.Stop()
+	c.Go(ctx, "keep_session", func(ctx context.Context) error {
+		c.keepSession(ctx)
+
+		return nil
+	})
+
+	if err := c.runControllers(ctx, c.controllers.General); err != nil {
+		return fmt.Errorf("[Monitor] run general controllers: %w", err)
+	}
+
+	// node is not a master on start
+	if err := c.runControllers(ctx, c.controllers.Slave); err != nil {
+		return fmt.Errorf("[Monitor] run slave controllers: %w", err)
+	}
+
+	c.Go(ctx, "watch_leadership", func(ctx context.Context) error {
+		c.watchLeadership(ctx)
+
+		return nil
+	})
+
+	c.SetState(controllers.StateRunning)
+
+	return nil
+}
+
+// ClusterStatus - return status of cluster: leader, healthy nodes and states of controllers of this node.
+func (c *Controller) ClusterStatus(_ context.Context) (ClusterStatus, error) {
+	nodes, err := c.consul.Peers()
+	if err != nil {
+		return ClusterStatus{}, fmt.Errorf("[Monitor] c.consul.Peers: %w", err)
+	}
+
+	c.mu.RLock()
+	isMaster, leader := c.isMaster, c.leader
+	c.mu.RUnlock()
+
+	return ClusterStatus{
+		NodeID:        c.consul.LocalLeaderInfo().NodeID,
+		IsLeader:      isMaster,
+		Leader:        leader,
+		Nodes:         nodes,
+		LastLeaderAck: c.consul.LastLeaderAck(),
+		Controllers:   c.statuses(),
+	}, nil
+}
+
+// statuses - statuses of monitor (first) and all controllers of this node.
+func (c *Controller) statuses() []controllers.Status {
+	statuses := []controllers.Status{c.Status()}
+
+	for _, group := range [][]controllers.DaemonController{
+		c.controllers.General, c.controllers.Master, c.controllers.Slave,
+	} {
+		for _, controller := range group {
+			statuses = append(statuses, controller.Status())
+		}
+	}
+
+	return statuses
+}
+
+// Ready - return error if node is not ready to serve: monitor or some of its running controllers are not healthy.
+func (c *Controller) Ready() error {
+	statuses := c.statuses()
+
+	for _, st := range statuses {
+		switch st.State {
+		case controllers.StateDegraded:
+			return fmt.Errorf("%w: %s: %s", ErrNotReady, st.Name, st.Health)
+		case controllers.StateStarting:
+			return fmt.Errorf("%w: %s is starting", ErrNotReady, st.Name)
+		}
+	}
+
+	if st := statuses[0]; st.State != controllers.StateRunning {
+		return fmt.Errorf("%w: %s is %s", ErrNotReady, st.Name, st.State)
+	}
+
+	return nil
+}
+
+// keepSession - keep one long-lived consul session, renew it periodically and recreate it if it was invalidated.
+func (c *Controller) keepSession(ctx context.Context) {
+	for {
+		err := c.consul.RenewSessionPeriodic(ctx.Done())
+		if ctx.Err() != nil {
+			return
+		}
+
+		c.Log.Warn("[Monitor] consul session was invalidated, create new one", field.Error(err))
+		c.consul.ResetSession()
 
 		for {
+			if _, err = c.consul.EnsureSession(); err == nil {
+				c.ReportHealth(nil)
+
+				break
+			}
+
+			c.Log.Error("[Monitor] can't create consul session", field.Error(err))
+			c.ReportHealth(fmt.Errorf("can't create consul session: %w", err))
+
 			select {
 			case <-ctx.Done():
-				c.Log.Warn("[Monitor] ctx.Done")
+				return
+			case <-time.After(c.config.timeoutConsulLeaderCheck):
+			}
+		}
+	}
+}
 
-				// nolint
-				c.shutdownAllMasterControllers()
+// watchLeadership - watch leader key by consul blocking queries and react on leadership changes.
+func (c *Controller) watchLeadership(ctx context.Context) {
+	c.Log.Info("[Monitor] Run")
+	defer c.Log.Info("[Monitor] Finished")
 
-				return
+	var waitIndex uint64
+
+	for {
+		if ctx.Err() != nil {
+			c.Log.Warn("[Monitor] ctx.Done")
+
+			// nolint
+			c.shutdownControllers(c.controllers.Master)
+			c.shutdownControllers(c.controllers.Slave)
+			c.shutdownControllers(c.controllers.General)
+			c.SetState(controllers.StateStopped)
+
+			return
+		}
+
+		c.Log.Debug("[Monitor] watch leadership", field.Uint64("waitIndex", waitIndex))
+
+		handovers := atomic.LoadUint64(&c.handovers)
+
+		state, err := c.consul.WatchLeadership(ctx, controllerMasterLockKey, waitIndex)
+		if err != nil {
+			if ctx.Err() != nil {
+				continue
+			}
 
-			case <-tConsulCoreLeader.C:
-				c.checkTrySetMasterFlag(ctx)
+			c.Log.Error("[Monitor] error while c.consul.WatchLeadership", field.Error(err))
+			c.ReportHealth(fmt.Errorf("can't watch leadership: %w", err))
 
-				if c.isMaster && !c.allControllersStarted {
-					// nolint
-					c.shutdownAllMasterControllers()
+			// If no connection with Consul longer than session TTL, we consider that we are not master anymore
+			// (probably somebody else has become a master)
+			c.transitionMu.Lock()
+			c.setMasterFlag(ctx, c.isMaster && !c.consul.IsLeaderAckExpired())
+			c.transitionMu.Unlock()
 
-					if err := c.consul.DestroySession(); err == nil {
-						c.isMaster = false
-					}
-				}
+			select {
+			case <-ctx.Done():
+			case <-time.After(c.config.timeoutConsulLeaderCheck):
 			}
+
+			continue
+		}
+
+		c.ReportHealth(nil)
+
+		c.transitionMu.Lock()
+		if atomic.LoadUint64(&c.handovers) != handovers {
+			// state was read before leader key was released by handover, it can say that node is still a master
+			c.transitionMu.Unlock()
+			c.Log.Debug("[Monitor] leadership state was read before handover, skip")
+
+			waitIndex = 0
+
+			continue
+		}
+
+		waitIndex = state.LastIndex
+
+		c.setLeader(state.Leader)
+		c.applyLeadershipState(ctx, state)
+		retry := state.NoLeader && !state.Handover && !c.isMaster
+		c.transitionMu.Unlock()
+
+		if retry {
+			// key was lost by crashed leader, it can't be acquired during lock delay of session of that leader,
+			// so try again soon instead of waiting next change of key
+			select {
+			case <-ctx.Done():
+			case <-time.After(c.config.timeoutConsulLeaderCheck):
+			}
+
+			waitIndex = 0
+		}
+	}
+}
+
+// applyLeadershipState - try become a master if there is no master, run or shutdown master controllers if needed.
+// Key handed over by other node is acquired at once, node which has handed it over (or has just stepped down)
+// does not acquire it during cooldown. Must be called under transitionMu.
+func (c *Controller) applyLeadershipState(ctx context.Context, state consul.LeadershipState) {
+	newIsMaster := state.IsLeader
+	if state.NoLeader {
+		handedOverByOther := state.Handover && state.HandoverFrom != c.consul.LocalLeaderInfo().NodeID
+		if !handedOverByOther && time.Now().Before(c.stepDownUntil) {
+			c.Log.Debug("[Monitor] no master, but node has just stepped down, skip")
+
+			return
+		}
+
+		newIsMaster = c.tryBecomeMaster()
+	}
+
+	c.setMasterFlag(ctx, newIsMaster)
+
+	if c.isMaster && !c.allControllersStarted {
+		// nolint
+		c.shutdownControllers(c.controllers.Master)
+
+		if _, err := c.consul.ReleaseSessionWithKey(controllerMasterLockKey); err == nil {
+			c.setMasterFlag(ctx, false)
+		}
+
+		// give a chance to other nodes become a master
+		c.stepDownUntil = time.Now().Add(c.config.timeoutConsulLeaderCheck)
+	}
+}
+
+// Handover - gracefully hand leadership over to other node: flush in-flight work of master controllers,
+// release leader key with "handover" marker and keep master controllers running until successor confirms
+// that it is running (or handover timeout expired). After that node does not try become a master during cooldown.
+func (c *Controller) Handover(ctx context.Context) error {
+	c.transitionMu.Lock()
+	defer c.transitionMu.Unlock()
+
+	if !c.isMaster {
+		return ErrNotMaster
+	}
+
+	c.Log.Info("[Monitor] start leadership handover")
+
+	ctx, cancel := context.WithTimeout(ctx, c.config.handoverTimeout)
+	defer cancel()
+
+	for _, controller := range c.controllers.Master {
+		if f, ok := controller.(controllers.Flusher); ok {
+			logger.LogIfError(c.Log, "[Monitor] error while flush controller", f.Flush(ctx),
+				field.Controller(controller.Status().Name))
 		}
-	}(ctx)
+	}
+
+	released, err := c.consul.ReleaseForHandover(controllerMasterLockKey)
+	if err != nil {
+		return fmt.Errorf("[Monitor] c.consul.ReleaseForHandover: %w", err)
+	}
+
+	atomic.AddUint64(&c.handovers, 1)
+
+	if released {
+		c.waitSuccessor(ctx)
+	}
+
+	c.stepDownUntil = time.Now().Add(c.config.handoverTimeout)
+	c.setMasterFlag(c.runCtx, false)
+
+	c.Log.Info("[Monitor] leadership handover finished")
 
 	return nil
 }
 
+// waitSuccessor - wait until other node acquires leader key and confirms that it is running.
+func (c *Controller) waitSuccessor(ctx context.Context) {
+	var waitIndex uint64
+
+	for {
+		state, err := c.consul.WatchLeadership(ctx, controllerMasterLockKey, waitIndex)
+		if ctx.Err() != nil {
+			c.Log.Warn("[Monitor] no successor confirmed leadership before handover timeout")
+
+			return
+		}
+
+		if err != nil {
+			c.Log.Error("[Monitor] error while waiting successor", field.Error(err))
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(c.config.timeoutConsulLeaderCheck):
+			}
+
+			continue
+		}
+
+		waitIndex = state.LastIndex
+
+		if !state.NoLeader && !state.IsLeader && state.Leader.Running {
+			c.Log.Info("[Monitor] successor confirmed leadership", field.String("leader", state.Leader.NodeID))
+			c.setLeader(state.Leader)
+
+			return
+		}
+	}
+}
+
 func (c *Controller) parseConfig(cfg controllers.Config) error {
 	var err error
 
@@ -116,72 +435,137 @@ func (c *Controller) parseConfig(cfg controllers.Config) error {
 		return fmt.Errorf("%s: can't parse cfg.General.Monitor.TimeoutConsulCoreLeaderCheck): %w", c.Name, err)
 	}
 
+	c.config.handoverTimeout, err = time.ParseDuration(cfg.General.Monitor.HandoverTimeout)
+	if err != nil {
+		return fmt.Errorf("%s: can't parse cfg.General.Monitor.HandoverTimeout): %w", c.Name, err)
+	}
+
+	policy, err := controllers.NewRestartPolicy(cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.Name, err)
+	}
+
+	c.SetRestartPolicy(policy)
+
 	return nil
 }
 
-func (c *Controller) runAllMasterControllers(ctx context.Context) error {
-	for _, controller := range c.masterControllers {
+// enabledControllers - filter out controllers, which are disabled by config.
+func (c *Controller) enabledControllers(
+	cfg controllers.Config,
+	group []controllers.DaemonController,
+) []controllers.DaemonController {
+	enabled := make([]controllers.DaemonController, 0, len(group))
+
+	for _, controller := range group {
+		if !cfg.IsEnabled(controller.Status().Name) {
+			c.Log.Info("[Monitor] controller is disabled", field.Controller(controller.Status().Name))
+
+			continue
+		}
+
+		enabled = append(enabled, controller)
+	}
+
+	return enabled
+}
+
+func (c *Controller) runControllers(ctx context.Context, group []controllers.DaemonController) error {
+	for _, controller := range group {
 		err := controller.Run(ctx)
 		if err != nil {
-			return fmt.Errorf("[Monitor] runAllMasterControllers: %w", err)
+			return fmt.Errorf("[Monitor] run controller %s: %w", controller.Status().Name, err)
 		}
 	}
 
 	return nil
 }
 
-func (c *Controller) shutdownAllMasterControllers() {
+func (c *Controller) shutdownControllers(group []controllers.DaemonController) {
 	shutdownCtx, cancel := context.WithTimeout(context.Background(), c.config.shutdownCtxTimeout)
 	defer cancel()
 
-	for _, controller := range c.masterControllers {
+	for _, controller := range group {
 		controller.Shutdown(shutdownCtx)
 	}
 }
 
-func (c *Controller) checkTrySetMasterFlag(ctx context.Context) {
-	c.Log.Debug("[Monitor] check leadership")
-
-	newIsMaster, noOneIsMaster := c.consul.CheckLeadership(c.isMaster, controllerMasterLockKey)
-
-	if noOneIsMaster {
-		c.Log.Warn("[Monitor] try become a master")
+func (c *Controller) tryBecomeMaster() bool {
+	c.Log.Warn("[Monitor] no master, try become a master")
 
-		var err error
-		newIsMaster, err = c.consul.TryBecomeLeader(controllerMasterLockKey)
-
-		if err != nil {
-			c.Log.Error("[Monitor] err while c.consul.TryBecomeLeader", field.Error(err))
+	isMaster, err := c.consul.TryBecomeLeader(controllerMasterLockKey)
+	if err != nil {
+		c.Log.Error("[Monitor] err while c.consul.TryBecomeLeader", field.Error(err))
 
-			newIsMaster = false
-		}
+		return false
 	}
 
-	c.setMasterFlag(ctx, newIsMaster)
+	return isMaster
 }
 
-// nolint nestif
 func (c *Controller) setMasterFlag(ctx context.Context, newIsMaster bool) {
-	if newIsMaster != c.isMaster {
-		if newIsMaster {
-			c.allControllersStarted = true
-			if err := c.runAllMasterControllers(ctx); err != nil {
-				c.allControllersStarted = false
-				c.Log.Error("[Monitor] runAllMasterControllers error", field.Error(err))
-			}
-		} else {
-			c.Log.Warn("[Monitor] I have lost master flag")
-			c.shutdownAllMasterControllers()
+	if newIsMaster == c.isMaster {
+		return
+	}
+
+	if newIsMaster {
+		c.Log.Info("[Monitor] I have become a master")
+		c.shutdownControllers(c.controllers.Slave)
+
+		c.allControllersStarted = true
+		if err := c.runControllers(ctx, c.controllers.Master); err != nil {
+			c.allControllersStarted = false
+			c.Log.Error("[Monitor] run master controllers error", field.Error(err))
+		} else if confirmed, err := c.consul.ConfirmLeadership(controllerMasterLockKey); err != nil {
+			c.Log.Error("[Monitor] error while c.consul.ConfirmLeadership", field.Error(err))
+		} else if !confirmed {
+			// leader key is held by other session already (e.g. state of key was stale)
+			c.Log.Warn("[Monitor] leadership is not confirmed, leader key is lost")
+			c.shutdownControllers(c.controllers.Master)
 			c.allControllersStarted = false
+
+			logger.LogIfError(c.Log, "[Monitor] run slave controllers error",
+				c.runControllers(ctx, c.controllers.Slave))
+
+			return
 		}
 	} else {
-		if newIsMaster { // условие newIsMaster эквивалентно newIsMaster == c.isMaster && c.isMaster
-			c.Log.Debug("[Monitor] try renew session")
-			if err := c.consul.RenewSession(); err != nil {
-				c.Log.Error("[Monitor] error while c.consul.RenewSession()", field.Error(err))
-			}
+		c.Log.Warn("[Monitor] I have lost master flag")
+		c.shutdownControllers(c.controllers.Master)
+		c.allControllersStarted = false
+
+		if ctx.Err() == nil {
+			logger.LogIfError(c.Log, "[Monitor] run slave controllers error",
+				c.runControllers(ctx, c.controllers.Slave))
 		}
 	}
 
+	c.mu.Lock()
 	c.isMaster = newIsMaster
+	if newIsMaster {
+		c.leader = c.consul.LocalLeaderInfo()
+	}
+	c.mu.Unlock()
+
+	c.publish()
+}
+
+// setLeader - remember current leader, emit event if leader was changed.
+func (c *Controller) setLeader(leader consul.LeaderInfo) {
+	c.mu.Lock()
+	changed := c.leader.NodeID != leader.NodeID
+	c.leader = leader
+	c.mu.Unlock()
+
+	if changed {
+		c.publish()
+	}
+}
+
+func (c *Controller) publish() {
+	c.mu.RLock()
+	e := leadership.Event{IsLeader: c.isMaster, Leader: c.leader.NodeID, Time: time.Now().UTC()}
+	c.mu.RUnlock()
+
+	c.bus.Publish(e)
 }
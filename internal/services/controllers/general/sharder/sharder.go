@@ -0,0 +1,302 @@
+// Package sharder - package for distribution of currencies (shards) across all healthy nodes
+package sharder
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/imperiuse/price_monitor/internal/consul"
+	"github.com/imperiuse/price_monitor/internal/hashring"
+	"github.com/imperiuse/price_monitor/internal/logger"
+	"github.com/imperiuse/price_monitor/internal/logger/field"
+	"github.com/imperiuse/price_monitor/internal/services/controllers"
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+const shardLockKeyPrefix = "pm/services/controllers/general/sharder/shards/"
+
+type (
+	// config - config of sharder Controller.
+	config struct {
+		enabled           bool
+		virtualNodes      int
+		intervalRebalance time.Duration
+	}
+
+	Currency = model.CurrencyCode
+
+	// Controller - sharder controller, watches healthy nodes in Consul, distributes currencies across them by
+	// consistent hashing and holds per-shard locks for currencies owned by this node. Locks are held by shared
+	// consul session of node, when it's invalidated (locks are deleted) and recreated, shards are acquired again.
+	Controller struct {
+		*controllers.Base
+
+		config     config
+		consul     *consul.Client
+		currencies []Currency
+
+		mu     sync.RWMutex // protect fields below
+		nodes  []string
+		owned  map[Currency]string // shards which locks are held by this node -> session which holds lock
+		cancel context.CancelFunc
+	}
+)
+
+// Name - name of sharder controller.
+const Name = "sharder"
+
+// New - constructor of sharder Controller.
+func New(cfg controllers.Config, l *logger.Logger, c *consul.Client) (*Controller, error) {
+	s := &Controller{
+		Base:       controllers.New(Name, l),
+		consul:     c,
+		currencies: model.Currencies,
+		owned:      map[Currency]string{},
+		cancel:     func() {},
+	}
+
+	if err := s.parseConfig(cfg); err != nil {
+		return nil, fmt.Errorf("sharder: s.parseConfig(cfg): %w", err)
+	}
+
+	return s, nil
+}
+
+func (s *Controller) parseConfig(cfg controllers.Config) error {
+	var err error
+
+	s.config.enabled = cfg.IsEnabled(Name)
+	s.config.virtualNodes = cfg.General.Sharder.VirtualNodes
+
+	s.config.intervalRebalance, err = time.ParseDuration(cfg.General.Sharder.IntervalRebalance)
+	if err != nil {
+		return fmt.Errorf("%s: can't parse time.ParseDuration(cfg.General.Sharder.IntervalRebalance): %w", s.Name, err)
+	}
+
+	policy, err := controllers.NewRestartPolicy(cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", s.Name, err)
+	}
+
+	s.SetRestartPolicy(policy)
+
+	return nil
+}
+
+// Register - register sharder as general controller (it runs on every node).
+func Register(s *Controller) controllers.Registration {
+	return controllers.AsGeneral(s)
+}
+
+// IsEnabled - is sharded mode enabled.
+func (s *Controller) IsEnabled() bool {
+	return s.config.enabled
+}
+
+// Owns - is currency owned by this node (always true if sharded mode is disabled). Shard is not owned anymore if its
+// lock was held by session which has been invalidated since.
+func (s *Controller) Owns(currency Currency) bool {
+	if !s.config.enabled {
+		return true
+	}
+
+	sessionID := s.consul.SessionID()
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return sessionID != "" && s.owned[currency] == sessionID
+}
+
+// Run - run controller func.
+func (s *Controller) Run(ctx context.Context) error {
+	if !s.config.enabled {
+		return nil
+	}
+
+	s.SetState(controllers.StateStarting)
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	s.mu.Lock()
+	s.cancel = cancel
+	s.mu.Unlock()
+
+	nodesCh := make(chan []string, 1)
+
+	s.Go(ctx, "watch_nodes", func(ctx context.Context) error {
+		s.watchNodes(ctx, nodesCh)
+
+		return nil
+	})
+
+	s.Go(ctx, "rebalance", func(ctx context.Context) error {
+		s.Log.Info("[Sharder] Run")
+		defer s.Log.Info("[Sharder] Finished")
+
+		t := time.NewTicker(s.config.intervalRebalance)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				s.releaseAll()
+
+				return nil
+
+			case nodes := <-nodesCh:
+				s.mu.Lock()
+				s.nodes = nodes
+				s.mu.Unlock()
+
+				s.rebalance()
+
+			case <-t.C:
+				s.rebalance() // retry to acquire shards which were still held by previous owners
+			}
+		}
+	})
+
+	s.SetState(controllers.StateRunning)
+
+	return nil
+}
+
+// Shutdown - shutdown func.
+func (s *Controller) Shutdown(_ context.Context) {
+	s.mu.RLock()
+	cancel := s.cancel
+	s.mu.RUnlock()
+
+	cancel()
+
+	s.SetState(controllers.StateStopped)
+}
+
+// watchNodes - watch healthy nodes by consul blocking queries, send set of nodes to nodesCh on every change.
+func (s *Controller) watchNodes(ctx context.Context, nodesCh chan<- []string) {
+	var waitIndex uint64
+
+	for ctx.Err() == nil {
+		state, err := s.consul.WatchNodes(ctx, waitIndex)
+		if err != nil {
+			if ctx.Err() == nil {
+				s.Log.Error("[Sharder] error while s.consul.WatchNodes", field.Error(err))
+				s.ReportHealth(fmt.Errorf("can't watch nodes: %w", err))
+			}
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(s.config.intervalRebalance):
+			}
+
+			continue
+		}
+
+		if state.LastIndex == waitIndex {
+			continue // wait timeout expired, nothing changed
+		}
+
+		s.ReportHealth(nil)
+
+		waitIndex = state.LastIndex
+
+		select {
+		case nodesCh <- state.NodeIDs:
+		case <-ctx.Done():
+		}
+	}
+}
+
+// rebalance - release shards which are not owned by node anymore and try to acquire locks of new own shards.
+func (s *Controller) rebalance() {
+	s.mu.RLock()
+	ring := hashring.New(s.config.virtualNodes, s.nodes...)
+	s.mu.RUnlock()
+
+	self := s.consul.LocalLeaderInfo()
+
+	for _, currency := range s.currencies {
+		isOwner := ring.Get(currency) == self.NodeID
+
+		s.mu.Lock()
+		lockSessionID, isLocked := s.owned[currency]
+		if isLocked && lockSessionID != self.SessionID {
+			// session was invalidated, its locks were deleted by Consul, so shard must be acquired again
+			s.Log.Warn("[Sharder] shard lock was lost with session", field.String("currency", currency))
+			delete(s.owned, currency)
+
+			isLocked = false
+		}
+		s.mu.Unlock()
+
+		switch {
+		case isOwner && !isLocked:
+			s.acquire(currency)
+		case !isOwner && isLocked:
+			s.release(currency)
+		}
+	}
+}
+
+func (s *Controller) acquire(currency Currency) {
+	sessionID, err := s.consul.EnsureSession()
+	if err != nil {
+		s.Log.Error("[Sharder] can't create consul session", field.Error(err))
+
+		return
+	}
+
+	acquired, err := s.consul.AcquireSessionWithKey(shardLockKey(currency))
+	if err != nil {
+		s.Log.Error("[Sharder] can't acquire shard lock", field.String("currency", currency), field.Error(err))
+
+		return
+	}
+
+	if !acquired {
+		s.Log.Debug("[Sharder] shard is still locked by other node", field.String("currency", currency))
+
+		return
+	}
+
+	s.Log.Info("[Sharder] shard acquired", field.String("currency", currency))
+
+	s.mu.Lock()
+	s.owned[currency] = sessionID
+	s.mu.Unlock()
+}
+
+func (s *Controller) release(currency Currency) {
+	// stop scanning first, only after that release lock
+	s.mu.Lock()
+	delete(s.owned, currency)
+	s.mu.Unlock()
+
+	if _, err := s.consul.ReleaseSessionWithKey(shardLockKey(currency)); err != nil {
+		s.Log.Error("[Sharder] can't release shard lock", field.String("currency", currency), field.Error(err))
+
+		return
+	}
+
+	s.Log.Info("[Sharder] shard released", field.String("currency", currency))
+}
+
+func (s *Controller) releaseAll() {
+	s.mu.RLock()
+	owned := make([]Currency, 0, len(s.owned))
+	for currency := range s.owned {
+		owned = append(owned, currency)
+	}
+	s.mu.RUnlock()
+
+	for _, currency := range owned {
+		s.release(currency)
+	}
+}
+
+func shardLockKey(currency Currency) string {
+	return shardLockKeyPrefix + currency
+}
@@ -0,0 +1,151 @@
+// Package waiter - package for waiting of monitorings finish (long polling of results)
+package waiter
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/imperiuse/price_monitor/internal/logger"
+	"github.com/imperiuse/price_monitor/internal/logger/field"
+	"github.com/imperiuse/price_monitor/internal/services/controllers"
+	"github.com/imperiuse/price_monitor/internal/services/storage"
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+type (
+	// Controller - waiter controller, listens notifications about finish of monitorings from Postgres
+	// (LISTEN/NOTIFY, so finish on any node is seen) and wakes up subscribers of finished monitorings.
+	// Notifications sent while listener is reconnecting are lost, so all subscribers are woken up on (re)connect.
+	Controller struct {
+		*controllers.Base
+
+		storage storage.Storage
+
+		mu          sync.Mutex // protect fields below
+		subscribers map[model.Identity]map[chan struct{}]struct{}
+		cancel      context.CancelFunc
+	}
+)
+
+// Name - name of waiter controller.
+const Name = "monitorings_waiter"
+
+// New - constructor of waiter Controller.
+func New(cfg controllers.Config, l *logger.Logger, s storage.Storage) (*Controller, error) {
+	w := &Controller{
+		Base:        controllers.New(Name, l),
+		storage:     s,
+		subscribers: map[model.Identity]map[chan struct{}]struct{}{},
+		cancel:      func() {},
+	}
+
+	policy, err := controllers.NewRestartPolicy(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("waiter: %s: %w", w.Name, err)
+	}
+
+	w.SetRestartPolicy(policy)
+
+	return w, nil
+}
+
+// Register - register waiter as general controller (it runs on every node).
+func Register(w *Controller) controllers.Registration {
+	return controllers.AsGeneral(w)
+}
+
+// Run - run controller func.
+func (w *Controller) Run(ctx context.Context) error {
+	w.SetState(controllers.StateStarting)
+
+	ctx, cancel := context.WithCancel(ctx)
+
+	w.mu.Lock()
+	w.cancel = cancel
+	w.mu.Unlock()
+
+	w.Go(ctx, "listen", func(ctx context.Context) error {
+		w.Log.Info("[Waiter] Run")
+		defer w.Log.Info("[Waiter] Finished")
+
+		err := storage.ListenMonitoringsFinished(ctx, w.storage, w.wakeAll, w.wake)
+		if err != nil && ctx.Err() == nil {
+			w.Log.Error("[Waiter] err while listen finish of monitorings", field.Error(err))
+
+			return err
+		}
+
+		return nil
+	})
+
+	w.SetState(controllers.StateRunning)
+
+	return nil
+}
+
+// Shutdown - shutdown func.
+func (w *Controller) Shutdown(_ context.Context) {
+	w.mu.Lock()
+	w.cancel()
+	w.mu.Unlock()
+
+	w.SetState(controllers.StateStopped)
+}
+
+// Subscribe - subscribe on finish of monitoring. Returned channel is closed when monitoring is finished (or when
+// it could be finished unnoticed, so status of monitoring must be checked again), unsubscribe func must be called.
+// Subscribe before check of status, then finish between check and waiting is not missed.
+func (w *Controller) Subscribe(id model.Identity) (<-chan struct{}, func()) {
+	ch := make(chan struct{})
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.subscribers[id] == nil {
+		w.subscribers[id] = map[chan struct{}]struct{}{}
+	}
+
+	w.subscribers[id][ch] = struct{}{}
+
+	return ch, func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+
+		if _, found := w.subscribers[id][ch]; !found { // already woken up
+			return
+		}
+
+		delete(w.subscribers[id], ch)
+
+		if len(w.subscribers[id]) == 0 {
+			delete(w.subscribers, id)
+		}
+	}
+}
+
+// wake - wake up subscribers of finished monitoring.
+func (w *Controller) wake(id model.Identity) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for ch := range w.subscribers[id] {
+		close(ch)
+	}
+
+	delete(w.subscribers, id)
+}
+
+// wakeAll - wake up all subscribers, used when notifications could be lost.
+func (w *Controller) wakeAll() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	for _, chs := range w.subscribers {
+		for ch := range chs {
+			close(ch)
+		}
+	}
+
+	w.subscribers = map[model.Identity]map[chan struct{}]struct{}{}
+}
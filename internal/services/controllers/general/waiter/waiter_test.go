@@ -0,0 +1,46 @@
+package waiter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imperiuse/price_monitor/internal/logger"
+	"github.com/imperiuse/price_monitor/internal/services/controllers"
+)
+
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestController_Subscribe(t *testing.T) {
+	w, err := New(controllers.Config{}, logger.NewNop(), nil)
+	require.NoError(t, err)
+
+	ch1, unsubscribe1 := w.Subscribe(1)
+	ch1b, unsubscribe1b := w.Subscribe(1)
+	ch2, unsubscribe2 := w.Subscribe(2)
+
+	w.wake(1)
+	assert.True(t, isClosed(ch1))
+	assert.True(t, isClosed(ch1b))
+	assert.False(t, isClosed(ch2), "other monitoring is not finished")
+
+	unsubscribe1() // after wake up it's no-op
+	unsubscribe1b()
+	unsubscribe2()
+	assert.Empty(t, w.subscribers)
+
+	ch3, unsubscribe3 := w.Subscribe(3)
+	defer unsubscribe3()
+
+	w.wakeAll()
+	assert.True(t, isClosed(ch3), "all subscribers are woken up on reconnect")
+	assert.Empty(t, w.subscribers)
+}
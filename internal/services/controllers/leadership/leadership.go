@@ -0,0 +1,74 @@
+// Package leadership - leadership events bus, allows controllers to subscribe on leadership changes of node.
+package leadership
+
+import (
+	"sync"
+	"time"
+)
+
+// subscriberBufferSize - size of subscriber chan buffer. Only the latest event matters for subscribers.
+const subscriberBufferSize = 1
+
+type (
+	// Event - leadership change event.
+	Event struct {
+		IsLeader bool      // is this node a leader now
+		Leader   string    // node id of current leader ("" - no leader)
+		Time     time.Time // time of leadership change (UTC)
+	}
+
+	// Bus - leadership events bus (one publisher -> many subscribers).
+	Bus struct {
+		mu          sync.Mutex
+		subscribers []chan Event
+		last        Event
+	}
+)
+
+// NewBus - constructor of leadership Bus.
+func NewBus() *Bus {
+	return &Bus{
+		subscribers: nil,
+		last:        Event{IsLeader: false, Time: time.Now().UTC()},
+	}
+}
+
+// Subscribe - subscribe on leadership events. Chan always keeps only the latest not read event.
+func (b *Bus) Subscribe() <-chan Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ch := make(chan Event, subscriberBufferSize)
+	b.subscribers = append(b.subscribers, ch)
+
+	return ch
+}
+
+// Publish - publish event to all subscribers, never blocks (slow subscriber lose old not read event).
+func (b *Bus) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.last = e
+
+	for _, ch := range b.subscribers {
+		select {
+		case ch <- e:
+		default:
+			// drop old not read event and put new one (publishing is under lock, so send never blocks)
+			select {
+			case <-ch:
+			default:
+			}
+			ch <- e
+		}
+	}
+}
+
+// Last - return last published event.
+func (b *Bus) Last() Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.last
+}
@@ -0,0 +1,37 @@
+package leadership
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBus_PublishSubscribe(t *testing.T) {
+	b := NewBus()
+	assert.False(t, b.Last().IsLeader)
+
+	ch1 := b.Subscribe()
+	ch2 := b.Subscribe()
+
+	e := Event{IsLeader: true, Time: time.Now().UTC()}
+	b.Publish(e)
+
+	assert.Equal(t, e, <-ch1)
+	assert.Equal(t, e, <-ch2)
+	assert.Equal(t, e, b.Last())
+}
+
+func TestBus_PublishNeverBlocks(t *testing.T) {
+	b := NewBus()
+	ch := b.Subscribe()
+
+	for i := 0; i < 10; i++ {
+		b.Publish(Event{IsLeader: i%2 == 0, Time: time.Now().UTC()})
+	}
+
+	// only the latest event is kept for slow subscriber
+	e := <-ch
+	assert.False(t, e.IsLeader)
+	assert.Equal(t, 0, len(ch))
+}
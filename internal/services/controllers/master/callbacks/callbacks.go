@@ -0,0 +1,391 @@
+// Package callbacks - package for delivery of results of finished monitorings to their callback urls
+package callbacks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/imperiuse/price_monitor/internal/helper"
+	"github.com/imperiuse/price_monitor/internal/logger"
+	"github.com/imperiuse/price_monitor/internal/logger/field"
+	"github.com/imperiuse/price_monitor/internal/services/controllers"
+	"github.com/imperiuse/price_monitor/internal/services/storage"
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+// Headers of callback request.
+const (
+	HeaderDelivery  = "X-PM-Delivery"  // id of callback, the same for retries (for deduplication by receiver)
+	HeaderTimestamp = "X-PM-Timestamp" // unix time of attempt
+	HeaderSignature = "X-PM-Signature" // sha256=hex(HMAC-SHA256(secret, timestamp + "." + body))
+)
+
+const maxErrorLen = 1000
+
+// ErrBadURL - callback url is not http(s) url or its host is internal address.
+var ErrBadURL = errors.New("bad callback url")
+
+type (
+	// config - config of callbacks Controller.
+	config struct {
+		intervalPeriodicCheck time.Duration
+		batchSize             uint64
+		timeout               time.Duration
+		maxAttempts           int
+		minBackoff            time.Duration
+		maxBackoff            time.Duration
+		secret                []byte
+		resultBaseURL         string
+		allowPrivateNetworks  bool
+	}
+
+	// ControllerDaemon - callbacks controller, posts results of finished monitorings to their callback urls.
+	// Callbacks are stored in outbox table (with monitoring), so delivery survives restarts and leader changes.
+	// Failed attempts are retried with exponential backoff, payload is built once and is the same for retries.
+	ControllerDaemon struct {
+		*controllers.Base
+
+		config  config
+		storage storage.Storage
+		client  *http.Client
+
+		cancelFunc context.CancelFunc
+	}
+
+	// Payload - body of callback request.
+	Payload struct {
+		MonitoringID    model.Identity
+		Status          model.MonitoringStatus
+		StatusChangedAt time.Time
+		StartAt         time.Time
+		FinishedAt      time.Time
+		Currency        model.CurrencyCode
+		Labels          model.Labels
+		Summary         *storage.PriceSummary `json:",omitempty"` // nil for cancelled monitoring
+		ResultURL       string                // link to prices of monitoring
+	}
+)
+
+const name = "monitorings_callbacks"
+
+// New - constructor of callbacks ControllerDaemon.
+func New(cfg controllers.Config, l *logger.Logger, s storage.Storage) (*ControllerDaemon, error) {
+	c := &ControllerDaemon{
+		Base:       controllers.New(name, l),
+		storage:    s,
+		cancelFunc: func() {},
+	}
+
+	if err := c.parseConfig(cfg); err != nil {
+		return nil, fmt.Errorf("callbacks: c.parseConfig(cfg): %w", err)
+	}
+
+	c.client = &http.Client{Timeout: c.config.timeout, Transport: c.transport()}
+
+	return c, nil
+}
+
+// transport - transport of callback requests, it refuses connections to internal addresses (unless private networks
+// are allowed), so neither redirect nor host re-resolved to other address makes master node post to internal services.
+func (c *ControllerDaemon) transport() *http.Transport {
+	// nolint forcetypeassert // default transport is always *http.Transport
+	t := http.DefaultTransport.(*http.Transport).Clone()
+
+	if !c.config.allowPrivateNetworks {
+		dialer := &net.Dialer{Timeout: c.config.timeout, Control: helper.PublicIPDialControl}
+
+		t.DialContext = dialer.DialContext
+		t.Proxy = nil // proxy would be checked instead of host of callback url
+	}
+
+	return t
+}
+
+// ValidateURL - validate callback url: it must be http(s) url and its host must not resolve to loopback, private or
+// link-local address (unless private networks are allowed by config), otherwise any client of api could make
+// master node post to internal services (Consul agent, cloud metadata etc).
+func (c *ControllerDaemon) ValidateURL(ctx context.Context, rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return fmt.Errorf("%w: must be http(s) url: %q", ErrBadURL, rawURL)
+	}
+
+	if c.config.allowPrivateNetworks {
+		return nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, u.Hostname())
+	if err != nil {
+		return fmt.Errorf("%w: can not resolve host %q: %v", ErrBadURL, u.Hostname(), err)
+	}
+
+	for _, addr := range addrs {
+		if !helper.IsPublicIP(addr.IP) {
+			return fmt.Errorf("%w: host %q resolves to internal address %s", ErrBadURL, u.Hostname(), addr.IP)
+		}
+	}
+
+	return nil
+}
+
+// Register - register callbacks as master controller.
+func Register(c *ControllerDaemon) controllers.Registration {
+	return controllers.AsMaster(c)
+}
+
+func (c *ControllerDaemon) parseConfig(cfg controllers.Config) error {
+	var err error
+
+	cc := cfg.Master.Callbacks
+
+	c.config.intervalPeriodicCheck, err = time.ParseDuration(cc.IntervalPeriodicCheck)
+	if err != nil {
+		return fmt.Errorf("%s: can't parse time.ParseDuration(cfg.Master.Callbacks.IntervalPeriodicCheck): %w",
+			c.Name, err)
+	}
+
+	c.config.timeout, err = time.ParseDuration(cc.Timeout)
+	if err != nil {
+		return fmt.Errorf("%s: can't parse time.ParseDuration(cfg.Master.Callbacks.Timeout): %w", c.Name, err)
+	}
+
+	c.config.minBackoff, err = time.ParseDuration(cc.MinBackoff)
+	if err != nil {
+		return fmt.Errorf("%s: can't parse time.ParseDuration(cfg.Master.Callbacks.MinBackoff): %w", c.Name, err)
+	}
+
+	c.config.maxBackoff, err = time.ParseDuration(cc.MaxBackoff)
+	if err != nil {
+		return fmt.Errorf("%s: can't parse time.ParseDuration(cfg.Master.Callbacks.MaxBackoff): %w", c.Name, err)
+	}
+
+	if cc.Secret == "" {
+		return fmt.Errorf("%s: secret of signature is empty", c.Name)
+	}
+
+	c.config.batchSize = uint64(cc.BatchSize)
+	c.config.maxAttempts = cc.MaxAttempts
+	c.config.secret = []byte(cc.Secret)
+	c.config.resultBaseURL = cc.ResultBaseURL
+	c.config.allowPrivateNetworks = cc.AllowPrivateNetworks
+
+	policy, err := controllers.NewRestartPolicy(cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.Name, err)
+	}
+
+	c.SetRestartPolicy(policy)
+
+	return nil
+}
+
+// Run - run controller func.
+func (c *ControllerDaemon) Run(ctx context.Context) error {
+	c.SetState(controllers.StateStarting)
+
+	ctx, cancel := context.WithCancel(ctx)
+	c.cancelFunc = cancel
+
+	c.Go(ctx, "deliver", func(ctx context.Context) error {
+		c.Log.Info("[Callbacks] Run")
+		defer c.Log.Info("[Callbacks] Finished")
+
+		t := time.NewTicker(c.config.intervalPeriodicCheck)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-t.C:
+				c.ReportHealth(c.deliver(ctx))
+			}
+		}
+	})
+
+	c.SetState(controllers.StateRunning)
+
+	return nil
+}
+
+// Shutdown - shutdown func.
+func (c *ControllerDaemon) Shutdown(_ context.Context) {
+	c.cancelFunc()
+
+	c.SetState(controllers.StateStopped)
+}
+
+// deliver - make delivery attempts of due callbacks. Failed attempts are not errors of controller (they are retried).
+func (c *ControllerDaemon) deliver(ctx context.Context) error {
+	now := time.Now().UTC()
+
+	callbacks, err := storage.DueCallbacks(ctx, c.storage, now, c.config.batchSize)
+	if err != nil {
+		c.Log.Error("[Callbacks] err while select due callbacks", field.Error(err))
+
+		return fmt.Errorf("can't select due callbacks: %w", err)
+	}
+
+	// nolint rangeValCopy
+	for _, cb := range callbacks {
+		if err = c.attempt(ctx, cb, now); err != nil {
+			c.Log.Error("[Callbacks] err while save attempt of callback", field.ID(cb.ID), field.Error(err))
+
+			return fmt.Errorf("can't save attempt of callback %d: %w", cb.ID, err)
+		}
+	}
+
+	return nil
+}
+
+// attempt - post payload of callback (build it on first attempt) and save result of attempt.
+func (c *ControllerDaemon) attempt(ctx context.Context, cb model.MonitoringCallback, now time.Time) error {
+	err := c.post(ctx, &cb)
+
+	cb.Attempts++
+
+	switch {
+	case err == nil:
+		cb.DeliveredAt = &now
+		cb.LastError = ""
+
+		c.Log.Info("[Callbacks] callback delivered", field.ID(cb.ID), field.Int64("monitoringID", cb.MonitoringID))
+	case cb.Attempts >= c.config.maxAttempts:
+		cb.FailedAt = &now
+		cb.LastError = truncate(err.Error())
+
+		c.Log.Warn("[Callbacks] delivery of callback is given up", field.ID(cb.ID), field.Error(err))
+	default:
+		cb.NextAttemptAt = now.Add(backoff(cb.Attempts, c.config.minBackoff, c.config.maxBackoff))
+		cb.LastError = truncate(err.Error())
+
+		c.Log.Debug("[Callbacks] delivery attempt of callback failed", field.ID(cb.ID), field.Error(err))
+	}
+
+	if ctx.Err() != nil { // attempt was interrupted by shutdown, it's not counted
+		return nil
+	}
+
+	return storage.SaveCallbackAttempt(ctx, c.storage, cb)
+}
+
+func (c *ControllerDaemon) post(ctx context.Context, cb *model.MonitoringCallback) error {
+	if cb.Payload == nil {
+		body, err := c.buildPayload(ctx, cb.MonitoringID)
+		if err != nil {
+			return err
+		}
+
+		payload := string(body)
+		cb.Payload = &payload
+	}
+
+	body := []byte(*cb.Payload)
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, cb.URL, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("can not create request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDelivery, strconv.FormatInt(cb.ID, 10))
+	req.Header.Set(HeaderTimestamp, ts)
+	req.Header.Set(HeaderSignature, Sign(c.config.secret, ts, body))
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("can not post callback: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		return fmt.Errorf("callback url responded with status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (c *ControllerDaemon) buildPayload(ctx context.Context, id model.Identity) ([]byte, error) {
+	var (
+		m   model.Monitoring
+		cur model.Currency
+	)
+
+	if err := c.storage.Connector().Repo(m).Get(ctx, id, &m); err != nil {
+		return nil, fmt.Errorf("can not get monitoring: %w", err)
+	}
+
+	if err := c.storage.Connector().Repo(cur).Get(ctx, m.CurrencyID, &cur); err != nil {
+		return nil, fmt.Errorf("can not get currency of monitoring: %w", err)
+	}
+
+	p := Payload{
+		MonitoringID:    m.ID,
+		Status:          m.Status,
+		StatusChangedAt: m.StatusChangedAt,
+		StartAt:         m.StartedAt,
+		FinishedAt:      m.ExpiredAt,
+		Currency:        cur.CurrencyCode,
+		Labels:          m.Labels,
+		ResultURL:       fmt.Sprintf("%s/api/v1/monitoring/%d", c.config.resultBaseURL, m.ID),
+	}
+
+	if m.Status != model.StatusCancelled {
+		summary, err := storage.SummarizePrices(ctx, c.storage, cur.CurrencyCode, m.StartedAt, m.ExpiredAt)
+		if err != nil {
+			return nil, err
+		}
+
+		p.Summary = &summary
+	}
+
+	body, err := json.Marshal(p)
+	if err != nil {
+		return nil, fmt.Errorf("can not marshal payload: %w", err)
+	}
+
+	return body, nil
+}
+
+// Sign - signature of body of callback: sha256=hex(HMAC-SHA256(secret, timestamp + "." + body)).
+func Sign(secret []byte, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff - delay before next attempt after attempts made: minBackoff doubled for every retry, at most maxBackoff.
+func backoff(attempts int, minBackoff, maxBackoff time.Duration) time.Duration {
+	d := minBackoff
+	for i := 1; i < attempts && d < maxBackoff; i++ {
+		d *= 2
+	}
+
+	if d > maxBackoff {
+		return maxBackoff
+	}
+
+	return d
+}
+
+func truncate(s string) string {
+	if len(s) > maxErrorLen {
+		return s[:maxErrorLen]
+	}
+
+	return s
+}
@@ -0,0 +1,55 @@
+package callbacks
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSign(t *testing.T) {
+	assert.Equal(t, "sha256=7c891d6515bbb388d3a170f8bf52e5dd048b5c91b0287d337eef65ba8e168c60",
+		Sign([]byte("secret"), "1641031200", []byte(`{"MonitoringID":1}`)))
+
+	assert.NotEqual(t, Sign([]byte("secret"), "1641031200", []byte(`{"MonitoringID":1}`)),
+		Sign([]byte("secret"), "1641031201", []byte(`{"MonitoringID":1}`)), "timestamp is signed too")
+}
+
+func Test_backoff(t *testing.T) {
+	minBackoff, maxBackoff := 5*time.Second, time.Minute
+
+	for attempts, want := range map[int]time.Duration{
+		1:  5 * time.Second,
+		2:  10 * time.Second,
+		3:  20 * time.Second,
+		4:  40 * time.Second,
+		5:  time.Minute,
+		50: time.Minute,
+	} {
+		assert.Equal(t, want, backoff(attempts, minBackoff, maxBackoff), attempts)
+	}
+}
+
+func TestControllerDaemon_ValidateURL(t *testing.T) {
+	c := &ControllerDaemon{}
+	ctx := context.Background()
+
+	assert.NoError(t, c.ValidateURL(ctx, "https://8.8.8.8/callback"))
+
+	for _, bad := range []string{
+		"ftp://8.8.8.8/callback",
+		"http:///callback",
+		"http://127.0.0.1:4000/admin/leadership/release",
+		"http://localhost:8500/v1/kv",
+		"http://169.254.169.254/latest/meta-data",
+		"http://10.0.0.1/callback",
+		"http://[::1]/callback",
+	} {
+		assert.ErrorIs(t, c.ValidateURL(ctx, bad), ErrBadURL, bad)
+	}
+
+	c.config.allowPrivateNetworks = true
+	assert.NoError(t, c.ValidateURL(ctx, "http://127.0.0.1:8080/callback"), "allowed by config")
+	assert.ErrorIs(t, c.ValidateURL(ctx, "ftp://127.0.0.1/callback"), ErrBadURL)
+}
@@ -0,0 +1,276 @@
+// Package cleaner - package for cleaning of expired monitorings and prices which are not needed anymore
+package cleaner
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/imperiuse/price_monitor/internal/logger"
+	"github.com/imperiuse/price_monitor/internal/logger/field"
+	"github.com/imperiuse/price_monitor/internal/services/controllers"
+	"github.com/imperiuse/price_monitor/internal/services/storage"
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+// Modes of cleaning of expired monitorings.
+const (
+	ModeDelete  = "delete"
+	ModeArchive = "archive"
+)
+
+// minPriceRetention - raw prices must outlive refresh window of candles (1d candles are refreshed over the last
+// 7 days, @see migrations), otherwise refresh re-materializes candles from pruned prices and drops them.
+const minPriceRetention = 8 * 24 * time.Hour
+
+var (
+	// ErrUnknownMode - unknown cleaning mode in config.
+	ErrUnknownMode = errors.New("unknown cleaner mode")
+
+	// ErrShortPriceRetention - price retention is shorter than refresh window of candles.
+	ErrShortPriceRetention = errors.New("price retention is too short")
+)
+
+type (
+	// config - config of cleaner Controller.
+	config struct {
+		retention             time.Duration
+		mode                  string
+		batchSize             int
+		intervalPeriodicClean time.Duration
+		prunePrices           bool
+		priceRetention        time.Duration
+	}
+
+	// ControllerDaemon - cleaner controller, removes (deletes or archives) monitorings expired earlier than retention
+	// ago and prunes prices older than price retention not covered by any retained monitoring. Everything is done by
+	// small batches.
+	ControllerDaemon struct {
+		*controllers.Base
+
+		config  config
+		storage storage.Storage
+
+		cancelFunc context.CancelFunc
+	}
+)
+
+const name = "monitorings_cleaner"
+
+// New - constructor of cleaner ControllerDaemon.
+func New(cfg controllers.Config, l *logger.Logger, s storage.Storage) (*ControllerDaemon, error) {
+	c := &ControllerDaemon{
+		Base:       controllers.New(name, l),
+		storage:    s,
+		cancelFunc: func() {},
+	}
+
+	if err := c.parseConfig(cfg); err != nil {
+		return nil, fmt.Errorf("cleaner: c.parseConfig(cfg): %w", err)
+	}
+
+	return c, nil
+}
+
+// Register - register cleaner as master controller.
+func Register(c *ControllerDaemon) controllers.Registration {
+	return controllers.AsMaster(c)
+}
+
+func (c *ControllerDaemon) parseConfig(cfg controllers.Config) error {
+	var err error
+
+	c.config.retention, err = time.ParseDuration(cfg.Master.Cleaner.Retention)
+	if err != nil {
+		return fmt.Errorf("%s: can't parse time.ParseDuration(cfg.Master.Cleaner.Retention): %w", c.Name, err)
+	}
+
+	c.config.intervalPeriodicClean, err = time.ParseDuration(cfg.Master.Cleaner.IntervalPeriodicClean)
+	if err != nil {
+		return fmt.Errorf("%s: can't parse time.ParseDuration(cfg.Master.Cleaner.IntervalPeriodicClean): %w",
+			c.Name, err)
+	}
+
+	c.config.mode = cfg.Master.Cleaner.Mode
+	if c.config.mode != ModeDelete && c.config.mode != ModeArchive {
+		return fmt.Errorf("%s: %w: %q", c.Name, ErrUnknownMode, c.config.mode)
+	}
+
+	c.config.batchSize = cfg.Master.Cleaner.BatchSize
+	c.config.prunePrices = cfg.Master.Cleaner.PrunePrices
+
+	if c.config.prunePrices {
+		c.config.priceRetention, err = time.ParseDuration(cfg.Master.Cleaner.PriceRetention)
+		if err != nil {
+			return fmt.Errorf("%s: can't parse time.ParseDuration(cfg.Master.Cleaner.PriceRetention): %w", c.Name, err)
+		}
+
+		if c.config.priceRetention < minPriceRetention {
+			return fmt.Errorf("%s: %w: %s < %s", c.Name, ErrShortPriceRetention, c.config.priceRetention,
+				minPriceRetention)
+		}
+	}
+
+	policy, err := controllers.NewRestartPolicy(cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.Name, err)
+	}
+
+	c.SetRestartPolicy(policy)
+
+	return nil
+}
+
+// Run - run controller func.
+func (c *ControllerDaemon) Run(ctx context.Context) error {
+	c.SetState(controllers.StateStarting)
+
+	ctx, cancel := context.WithCancel(ctx)
+	c.cancelFunc = cancel
+
+	c.Go(ctx, "clean", func(ctx context.Context) error {
+		c.Log.Info("[Cleaner] Run")
+		defer c.Log.Info("[Cleaner] Finished")
+
+		t := time.NewTicker(c.config.intervalPeriodicClean)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-t.C:
+				c.ReportHealth(c.clean(ctx))
+			}
+		}
+	})
+
+	c.SetState(controllers.StateRunning)
+
+	return nil
+}
+
+// Shutdown - shutdown func.
+func (c *ControllerDaemon) Shutdown(_ context.Context) {
+	c.cancelFunc()
+
+	c.SetState(controllers.StateStopped)
+}
+
+// clean - remove expired monitorings and prune prices.
+func (c *ControllerDaemon) clean(ctx context.Context) error {
+	before := time.Now().UTC().Add(-c.config.retention)
+
+	cnt, err := c.inBatches(ctx, func(ctx context.Context) (int64, error) {
+		return c.removeMonitorings(ctx, before)
+	})
+	if err != nil {
+		c.Log.Error("[Cleaner] err while remove expired monitorings", field.Error(err))
+
+		return fmt.Errorf("can't remove expired monitorings: %w", err)
+	}
+
+	if cnt > 0 {
+		c.Log.Info("[Cleaner] expired monitorings removed", field.String("mode", c.config.mode), field.Int64("cnt", cnt))
+	}
+
+	if !c.config.prunePrices {
+		return nil
+	}
+
+	before = time.Now().UTC().Add(-c.config.priceRetention)
+
+	for _, currency := range model.Currencies {
+		currency := currency
+
+		cnt, err = c.inBatches(ctx, func(ctx context.Context) (int64, error) {
+			return c.prunePrices(ctx, currency, before)
+		})
+		if err != nil {
+			c.Log.Error("[Cleaner] err while prune prices", field.String("currency", currency), field.Error(err))
+
+			return fmt.Errorf("can't prune prices of %s: %w", currency, err)
+		}
+
+		if cnt > 0 {
+			c.Log.Info("[Cleaner] prices pruned", field.String("currency", currency), field.Int64("cnt", cnt))
+		}
+	}
+
+	return nil
+}
+
+// inBatches - call batch func until it removes less rows than batch size, return total cnt of removed rows.
+func (c *ControllerDaemon) inBatches(ctx context.Context, batch func(context.Context) (int64, error)) (int64, error) {
+	var total int64
+
+	for ctx.Err() == nil {
+		cnt, err := batch(ctx)
+		if err != nil {
+			return total, err
+		}
+
+		total += cnt
+
+		if cnt < int64(c.config.batchSize) {
+			break
+		}
+	}
+
+	return total, nil
+}
+
+// removeMonitorings - delete (or move to archive) one batch of monitorings expired and finished before time
+// (historical monitoring is expired since its creation, so it's kept for retention after it has been finished).
+func (c *ControllerDaemon) removeMonitorings(ctx context.Context, before time.Time) (int64, error) {
+	query := fmt.Sprintf(`
+WITH batch AS (
+	SELECT id FROM %[1]s WHERE expired_at < $1 AND status_changed_at < $1 ORDER BY id LIMIT $2 FOR UPDATE SKIP LOCKED
+)
+DELETE FROM %[1]s m USING batch WHERE m.id = batch.id`, model.Monitoring{}.Repo())
+
+	if c.config.mode == ModeArchive {
+		query = fmt.Sprintf(`
+WITH batch AS (
+	SELECT id FROM %[1]s WHERE expired_at < $1 AND status_changed_at < $1 ORDER BY id LIMIT $2 FOR UPDATE SKIP LOCKED
+), moved AS (
+	DELETE FROM %[1]s m USING batch WHERE m.id = batch.id RETURNING m.*
+)
+INSERT INTO %[2]s(id, data) SELECT moved.id, to_jsonb(moved) FROM moved
+ON CONFLICT (id) DO UPDATE SET data = EXCLUDED.data, archived_at = NOW()`,
+			model.Monitoring{}.Repo(), model.MonitoringsArchiveTable)
+	}
+
+	return c.exec(ctx, query, before, c.config.batchSize)
+}
+
+// prunePrices - delete one batch of prices older than time, which are not covered by any retained monitoring.
+func (c *ControllerDaemon) prunePrices(ctx context.Context, currency model.CurrencyCode, before time.Time) (int64, error) {
+	query := fmt.Sprintf(`
+DELETE FROM %[1]s WHERE time IN (
+	SELECT p.time FROM %[1]s p
+	WHERE p.time < $1 AND NOT EXISTS (
+		SELECT 1 FROM %[2]s m
+		JOIN %[3]s c ON c.id = m.currency_id
+		WHERE c.currency_code = $2 AND p.time BETWEEN m.started_at AND m.expired_at
+	)
+	LIMIT $3
+)`, model.PriceTableNameGetterFunc(currency), model.Monitoring{}.Repo(), model.Currency{}.Repo())
+
+	return c.exec(ctx, query, before, currency, c.config.batchSize)
+}
+
+func (c *ControllerDaemon) exec(ctx context.Context, query string, args ...any) (int64, error) {
+	res, err := c.storage.PureSqlxDB().ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("c.storage.PureSqlxDB().ExecContext: %w", err)
+	}
+
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("res.RowsAffected: %w", err)
+	}
+
+	return cnt, nil
+}
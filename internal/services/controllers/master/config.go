@@ -1,5 +1,8 @@
 package master
 
+// PlaceholderCallbackSecret - default secret of callbacks signature from config, it must be overridden out of dev env.
+const PlaceholderCallbackSecret = "change-me"
+
 // Config - config for all master controllers.
 type Config struct {
 	Scanner struct {
@@ -11,5 +14,75 @@ type Config struct {
 
 		// CntScanWorkers - cnt of workers
 		CntWorkers int `yaml:"cntWorkers"`
+
+		// MaxFailuresInRow - scanner is degraded (node is not ready) only if scan of some currency failed so many
+		// times in a row, single failures of flaky market api do not affect readiness
+		MaxFailuresInRow int `yaml:"maxFailuresInRow"`
 	} `yaml:"scanner"`
+
+	Cleaner struct {
+		// Retention - monitorings which expired earlier than retention ago are removed
+		Retention string `yaml:"retention"`
+
+		// Mode - what to do with expired monitorings: "delete" or "archive" (move to monitorings_archive table)
+		Mode string `yaml:"mode"`
+
+		// BatchSize - max cnt of rows removed by one query (small batches do not lock tables for a long time)
+		BatchSize int `yaml:"batchSize"`
+
+		// IntervalPeriodicClean - clean interval
+		IntervalPeriodicClean string `yaml:"intervalPeriodicClean"`
+
+		// PrunePrices - also delete prices older than price retention, which are not covered by any retained monitoring
+		PrunePrices bool `yaml:"prunePrices"`
+
+		// PriceRetention - prices younger than it are always kept (candles are refreshed from them, history of prices),
+		// it must be longer than refresh window of continuous aggregates of candles (@see migrations)
+		PriceRetention string `yaml:"priceRetention"`
+	} `yaml:"cleaner"`
+
+	Lifecycle struct {
+		// IntervalPeriodicCheck - interval of checking monitorings which should change status (start or finish)
+		IntervalPeriodicCheck string `yaml:"intervalPeriodicCheck"`
+
+		// BatchSize - max cnt of monitorings which change status by one query
+		BatchSize int `yaml:"batchSize"`
+	} `yaml:"lifecycle"`
+
+	Scheduler struct {
+		// IntervalPeriodicCheck - interval of checking templates of recurring monitorings, which runs are due
+		IntervalPeriodicCheck string `yaml:"intervalPeriodicCheck"`
+
+		// BatchSize - max cnt of templates materialized by one check
+		BatchSize int `yaml:"batchSize"`
+	} `yaml:"scheduler"`
+
+	Callbacks struct {
+		// IntervalPeriodicCheck - interval of checking callbacks of finished monitorings, which delivery is due
+		IntervalPeriodicCheck string `yaml:"intervalPeriodicCheck"`
+
+		// BatchSize - max cnt of callbacks delivered by one check
+		BatchSize int `yaml:"batchSize"`
+
+		// Timeout - timeout of one delivery attempt
+		Timeout string `yaml:"timeout"`
+
+		// MaxAttempts - max cnt of delivery attempts, then delivery is given up
+		MaxAttempts int `yaml:"maxAttempts"`
+
+		// MinBackoff - delay before first retry (doubled for every next retry)
+		MinBackoff string `yaml:"minBackoff"`
+
+		// MaxBackoff - max delay before retry
+		MaxBackoff string `yaml:"maxBackoff"`
+
+		// Secret - HMAC-SHA256 key of payload signature (env PM_CALLBACK_SECRET overrides it)
+		Secret string `yaml:"secret"`
+
+		// ResultBaseURL - base url of api for link to result of monitoring in payload
+		ResultBaseURL string `yaml:"resultBaseURL"`
+
+		// AllowPrivateNetworks - allow callback urls with loopback, private and link-local addresses (local development)
+		AllowPrivateNetworks bool `yaml:"allowPrivateNetworks"`
+	} `yaml:"callbacks"`
 }
@@ -0,0 +1,205 @@
+// Package lifecycle - package for driving monitorings through lifecycle statuses
+package lifecycle
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/imperiuse/price_monitor/internal/logger"
+	"github.com/imperiuse/price_monitor/internal/logger/field"
+	"github.com/imperiuse/price_monitor/internal/services/controllers"
+	"github.com/imperiuse/price_monitor/internal/services/storage"
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+// Reasons of transitions made by lifecycle controller.
+const (
+	ReasonStarted          = "started_at has come"
+	ReasonFinishedWithData = "expired_at has come, prices were collected"
+	ReasonFinishedNoData   = "expired_at has come, no prices were collected"
+)
+
+type (
+	// config - config of lifecycle Controller.
+	config struct {
+		intervalPeriodicCheck time.Duration
+		batchSize             int
+	}
+
+	// ControllerDaemon - lifecycle controller, starts scheduled monitorings (scheduled -> running) and finishes
+	// expired ones (running -> completed, or failed if no prices were collected). Every transition is recorded.
+	ControllerDaemon struct {
+		*controllers.Base
+
+		config  config
+		storage storage.Storage
+
+		cancelFunc context.CancelFunc
+	}
+)
+
+const name = "monitorings_lifecycle"
+
+// New - constructor of lifecycle ControllerDaemon.
+func New(cfg controllers.Config, l *logger.Logger, s storage.Storage) (*ControllerDaemon, error) {
+	c := &ControllerDaemon{
+		Base:       controllers.New(name, l),
+		storage:    s,
+		cancelFunc: func() {},
+	}
+
+	if err := c.parseConfig(cfg); err != nil {
+		return nil, fmt.Errorf("lifecycle: c.parseConfig(cfg): %w", err)
+	}
+
+	return c, nil
+}
+
+// Register - register lifecycle as master controller.
+func Register(c *ControllerDaemon) controllers.Registration {
+	return controllers.AsMaster(c)
+}
+
+func (c *ControllerDaemon) parseConfig(cfg controllers.Config) error {
+	var err error
+
+	c.config.intervalPeriodicCheck, err = time.ParseDuration(cfg.Master.Lifecycle.IntervalPeriodicCheck)
+	if err != nil {
+		return fmt.Errorf("%s: can't parse time.ParseDuration(cfg.Master.Lifecycle.IntervalPeriodicCheck): %w",
+			c.Name, err)
+	}
+
+	c.config.batchSize = cfg.Master.Lifecycle.BatchSize
+
+	policy, err := controllers.NewRestartPolicy(cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.Name, err)
+	}
+
+	c.SetRestartPolicy(policy)
+
+	return nil
+}
+
+// Run - run controller func.
+func (c *ControllerDaemon) Run(ctx context.Context) error {
+	c.SetState(controllers.StateStarting)
+
+	ctx, cancel := context.WithCancel(ctx)
+	c.cancelFunc = cancel
+
+	c.Go(ctx, "transit", func(ctx context.Context) error {
+		c.Log.Info("[Lifecycle] Run")
+		defer c.Log.Info("[Lifecycle] Finished")
+
+		t := time.NewTicker(c.config.intervalPeriodicCheck)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-t.C:
+				c.ReportHealth(c.transit(ctx))
+			}
+		}
+	})
+
+	c.SetState(controllers.StateRunning)
+
+	return nil
+}
+
+// Shutdown - shutdown func.
+func (c *ControllerDaemon) Shutdown(_ context.Context) {
+	c.cancelFunc()
+
+	c.SetState(controllers.StateStopped)
+}
+
+// transit - start scheduled and finish expired monitorings.
+func (c *ControllerDaemon) transit(ctx context.Context) error {
+	now := time.Now().UTC()
+
+	cnt, err := c.start(ctx, now)
+	if err != nil {
+		c.Log.Error("[Lifecycle] err while start monitorings", field.Error(err))
+
+		return fmt.Errorf("can't start monitorings: %w", err)
+	}
+
+	if cnt > 0 {
+		c.Log.Debug("[Lifecycle] monitorings started", field.Int64("cnt", cnt))
+	}
+
+	for _, currency := range model.Currencies {
+		cnt, err = c.finish(ctx, currency, now)
+		if err != nil {
+			c.Log.Error("[Lifecycle] err while finish monitorings", field.String("currency", currency), field.Error(err))
+
+			return fmt.Errorf("can't finish monitorings of %s: %w", currency, err)
+		}
+
+		if cnt > 0 {
+			c.Log.Debug("[Lifecycle] monitorings finished", field.String("currency", currency), field.Int64("cnt", cnt))
+		}
+	}
+
+	return nil
+}
+
+// start - scheduled -> running for one batch of monitorings, which started_at has come.
+func (c *ControllerDaemon) start(ctx context.Context, now time.Time) (int64, error) {
+	return c.exec(ctx, fmt.Sprintf(`
+WITH changed AS (
+	UPDATE %[1]s SET status = $2, status_changed_at = $3
+	WHERE id IN (
+		SELECT id FROM %[1]s WHERE status = $1 AND started_at <= $3 ORDER BY id LIMIT $4 FOR UPDATE SKIP LOCKED
+	)
+	RETURNING id
+)
+INSERT INTO %[2]s(monitoring_id, from_status, to_status, reason, created_at)
+SELECT id, $1, $2, $5, $3 FROM changed`, model.Monitoring{}.Repo(), model.MonitoringTransition{}.Repo()),
+		model.StatusScheduled, model.StatusRunning, now, c.config.batchSize, ReasonStarted,
+	)
+}
+
+// finish - running -> completed (or failed if there are no prices) for one batch of expired monitorings.
+func (c *ControllerDaemon) finish(ctx context.Context, currency model.CurrencyCode, now time.Time) (int64, error) {
+	return c.exec(ctx, fmt.Sprintf(`
+WITH changed AS (
+	UPDATE %[1]s m SET
+		status = CASE WHEN EXISTS (
+			SELECT 1 FROM %[3]s p WHERE p.time BETWEEN m.started_at AND m.expired_at
+		) THEN $3 ELSE $4 END,
+		status_changed_at = $5
+	WHERE m.id IN (
+		SELECT id FROM %[1]s
+		WHERE status = $2 AND expired_at <= $5 AND currency_id = (SELECT id FROM %[2]s WHERE currency_code = $1)
+		ORDER BY id LIMIT $6 FOR UPDATE SKIP LOCKED
+	)
+	RETURNING m.id, m.status
+)
+INSERT INTO %[4]s(monitoring_id, from_status, to_status, reason, created_at)
+SELECT id, $2, status, CASE WHEN status = $3 THEN $7 ELSE $8 END, $5 FROM changed`,
+		model.Monitoring{}.Repo(), model.Currency{}.Repo(), model.PriceTableNameGetterFunc(currency),
+		model.MonitoringTransition{}.Repo()),
+		currency, model.StatusRunning, model.StatusCompleted, model.StatusFailed, now, c.config.batchSize,
+		ReasonFinishedWithData, ReasonFinishedNoData,
+	)
+}
+
+func (c *ControllerDaemon) exec(ctx context.Context, query string, args ...any) (int64, error) {
+	res, err := c.storage.PureSqlxDB().ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, fmt.Errorf("c.storage.PureSqlxDB().ExecContext: %w", err)
+	}
+
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("res.RowsAffected: %w", err)
+	}
+
+	return cnt, nil
+}
@@ -3,7 +3,10 @@ package scanner
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/imperiuse/price_monitor/internal/logger"
@@ -20,26 +23,57 @@ type (
 		cntWorkers            int
 		timeoutOneTaskProcess time.Duration
 		intervalPeriodicScan  time.Duration
+		maxFailuresInRow      int
 	}
 
 	Currency = model.CurrencyCode
 
 	taskChan = chan Currency
 
+	// ShardOwner - decides which currencies should be scanned by this node.
+	ShardOwner interface {
+		IsEnabled() bool // is sharded mode enabled
+		Owns(Currency) bool
+	}
+
+	// SampleObserver - observes every new sample of price stored by scanner (e.g. evaluates alert rules).
+	SampleObserver interface {
+		Observe(ctx context.Context, currency Currency, t time.Time, price float64) error
+	}
+
 	// ControllerDaemon - scanner controller.
 	ControllerDaemon struct {
 		*controllers.Base
 
-		config  config
-		storage storage.Storage
-		market  market.Market
+		config   config
+		storage  storage.Storage
+		market   market.Market
+		owner    ShardOwner
+		observer SampleObserver
 
 		taskCh            taskChan
+		inFlightTasks     int64 // cnt of tasks which were sent to taskCh and have not been processed yet
 		cancelWorkersFunc context.CancelFunc
+
+		failures failures
+	}
+
+	// failures - failed scans in a row by currencies.
+	failures struct {
+		mu      sync.Mutex // protect fields below
+		inRow   map[Currency]int
+		lastErr map[Currency]error
 	}
 )
 
-const name = "price_scanner"
+// ErrScansFailed - scans of currency failed too many times in a row.
+var ErrScansFailed = errors.New("scans failed in a row")
+
+const (
+	name = "price_scanner"
+
+	flushCheckInterval = 50 * time.Millisecond
+)
 
 // New - constructor of scanner ControllerDaemon.
 func New(
@@ -47,13 +81,21 @@ func New(
 	l *logger.Logger,
 	s storage.Storage,
 	m market.Market,
+	owner ShardOwner,
+	observer SampleObserver,
 ) (*ControllerDaemon, error) {
 	c := &ControllerDaemon{
 		Base:              controllers.New(name, l),
 		config:            config{},
 		storage:           s,
 		market:            m,
+		owner:             owner,
+		observer:          observer,
 		cancelWorkersFunc: func() {},
+		failures: failures{
+			inRow:   map[Currency]int{},
+			lastErr: map[Currency]error{},
+		},
 	}
 
 	c.Base.RegisterShutdownFunc(
@@ -69,11 +111,26 @@ func New(
 	return c, nil
 }
 
+// Register - register scanner as master controller. In sharded mode every node scans own shards,
+// so scanner is registered as general controller.
+func Register(c *ControllerDaemon) controllers.Registration {
+	if c.owner.IsEnabled() {
+		return controllers.AsGeneral(c)
+	}
+
+	return controllers.AsMaster(c)
+}
+
 func (c *ControllerDaemon) parseConfig(cfg controllers.Config) error {
 	var err error
 
 	c.config.cntWorkers = cfg.Master.Scanner.CntWorkers
 
+	c.config.maxFailuresInRow = cfg.Master.Scanner.MaxFailuresInRow
+	if c.config.maxFailuresInRow < 1 {
+		return fmt.Errorf("%s: maxFailuresInRow must be positive: %d", c.Name, c.config.maxFailuresInRow)
+	}
+
 	c.config.timeoutOneTaskProcess, err = time.ParseDuration(cfg.Master.Scanner.TimeoutOneTaskProcess)
 	if err != nil {
 		return fmt.Errorf("%s: can't parse time.ParseDuration(c.config.TimeoutProcessOneRC): %w", c.Name, err)
@@ -85,34 +142,50 @@ func (c *ControllerDaemon) parseConfig(cfg controllers.Config) error {
 			c.Name, err)
 	}
 
+	policy, err := controllers.NewRestartPolicy(cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.Name, err)
+	}
+
+	c.SetRestartPolicy(policy)
+
 	return nil
 }
 
 // Run - run controller func.
 func (c *ControllerDaemon) Run(ctx context.Context) error {
+	c.SetState(controllers.StateStarting)
+
 	ctx, cancel := context.WithCancel(ctx)
 	c.cancelWorkersFunc = cancel
 
-	go func(ctx context.Context) {
+	for i := 0; i < c.config.cntWorkers; i++ {
+		workerID := i
+		c.Go(ctx, fmt.Sprintf("scan_worker_%d", workerID), func(ctx context.Context) error {
+			c.scanWorker(ctx, workerID)
+
+			return nil
+		})
+	}
+
+	c.Go(ctx, "prepare_tasks", func(ctx context.Context) error {
 		c.Log.Info("[Scanner] Run")
 		defer c.Log.Info("[Scanner] Finished")
 
 		t := time.NewTicker(c.config.intervalPeriodicScan)
 		defer t.Stop()
 
-		for i := 0; i < c.config.cntWorkers; i++ {
-			go c.scanWorker(ctx, i)
-		}
-
 		for {
 			select {
 			case <-ctx.Done():
-				return
+				return nil
 			case <-t.C:
 				c.prepareTasks(ctx)
 			}
 		}
-	}(ctx)
+	})
+
+	c.SetState(controllers.StateRunning)
 
 	return nil
 }
@@ -122,11 +195,18 @@ func (c *ControllerDaemon) prepareTasks(ctx context.Context) {
 	c.Log.Debug("[Scanner] prepareTasks start")
 
 	// nolint rangeValCopy
-	for _, v := range []Currency{model.BtcUsd} {
+	for _, v := range model.Currencies {
+		if !c.owner.Owns(v) {
+			continue
+		}
+
+		atomic.AddInt64(&c.inFlightTasks, 1)
+
 		select {
 		case c.taskCh <- v:
 
 		case <-ctx.Done():
+			atomic.AddInt64(&c.inFlightTasks, -1)
 			c.Log.Warn("[Scanner] prepareTasks ctx.Done")
 
 			return
@@ -139,6 +219,24 @@ func (c *ControllerDaemon) prepareTasks(ctx context.Context) {
 // Shutdown - shutdown func.
 func (c *ControllerDaemon) Shutdown(_ context.Context) {
 	c.cancelWorkersFunc()
+
+	c.SetState(controllers.StateStopped)
+}
+
+// Flush - wait until all in-flight scan tasks are processed (scanner keeps running).
+func (c *ControllerDaemon) Flush(ctx context.Context) error {
+	t := time.NewTicker(flushCheckInterval)
+	defer t.Stop()
+
+	for atomic.LoadInt64(&c.inFlightTasks) > 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("[Scanner] flush: %w", ctx.Err())
+		case <-t.C:
+		}
+	}
+
+	return nil
 }
 
 func (c *ControllerDaemon) scanWorker(ctx context.Context, workerID int) {
@@ -155,28 +253,66 @@ func (c *ControllerDaemon) scanWorker(ctx context.Context, workerID int) {
 				c.Log.Error("[ScanWorker] received bad value from c.taskCh")
 			}
 
-			if err := c.processTask(ctx, currency); err != nil {
-				c.Log.Error("err while process task", field.Int("workerID", workerID), field.Error(err))
-			}
+			c.processInFlightTask(ctx, workerID, currency)
 		}
 	}
 }
 
-func (c *ControllerDaemon) processTask(ctx context.Context, currency Currency) error {
-	const one = 1
+// processInFlightTask - process task and mark it as done (even if processing panicked).
+func (c *ControllerDaemon) processInFlightTask(ctx context.Context, workerID int, currency Currency) {
+	defer atomic.AddInt64(&c.inFlightTasks, -1)
+
+	err := c.processTask(ctx, currency)
+	if err != nil {
+		c.Log.Error("err while process task", field.Int("workerID", workerID), field.Error(err))
+	}
 
+	c.ReportHealth(c.failures.track(currency, err, c.config.maxFailuresInRow))
+}
+
+// track - track result of scan of currency, return health error if scans of some currency failed max times in a row.
+func (f *failures) track(currency Currency, err error, maxInRow int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err == nil {
+		delete(f.inRow, currency)
+		delete(f.lastErr, currency)
+	} else {
+		f.inRow[currency]++
+		f.lastErr[currency] = err
+	}
+
+	for cur, cnt := range f.inRow {
+		if cnt >= maxInRow {
+			return fmt.Errorf("%w: %s: %d times, last: %v", ErrScansFailed, cur, cnt, f.lastErr[cur])
+		}
+	}
+
+	return nil
+}
+
+func (c *ControllerDaemon) processTask(ctx context.Context, currency Currency) error {
 	t, price, err := c.market.GetActualPrice(ctx, currency)
 	if err != nil {
 		return err
 	}
 
-	cnt, err := c.storage.Connector().RepoByName(model.PriceTableNameGetterFunc(currency)).
-		Insert(ctx, []string{"time", "price"}, []any{t.Round(1000 * time.Millisecond), price})
+	t = t.Round(1000 * time.Millisecond)
+
+	inserted, err := storage.InsertPrice(ctx, c.storage, currency, t, price)
 	if err != nil {
 		return err
 	}
-	if cnt != one {
-		return storage.ErrNotInserted
+	if !inserted { // already stored by other node (both old and new master scan during handover)
+		c.Log.Debug("[Scanner] price is already stored", field.String("currency", currency), field.Any("time", t))
+
+		return nil
+	}
+
+	// sample is stored anyway, so error of observer does not fail scanning
+	if err = c.observer.Observe(ctx, currency, t, price); err != nil {
+		c.Log.Error("[Scanner] err while observe sample", field.String("currency", currency), field.Error(err))
 	}
 
 	return nil
@@ -0,0 +1,25 @@
+package scanner
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+func Test_failures_track(t *testing.T) {
+	f := failures{inRow: map[Currency]int{}, lastErr: map[Currency]error{}}
+	errAPI := errors.New("market api is unavailable")
+
+	assert.NoError(t, f.track(model.BtcUsd, errAPI, 3))
+	assert.NoError(t, f.track(model.BtcUsd, errAPI, 3))
+	assert.NoError(t, f.track(model.BtcUsd, nil, 3), "success resets failures in a row")
+
+	assert.NoError(t, f.track(model.BtcUsd, errAPI, 3))
+	assert.NoError(t, f.track(model.BtcUsd, errAPI, 3))
+	assert.ErrorIs(t, f.track(model.BtcUsd, errAPI, 3), ErrScansFailed)
+
+	assert.NoError(t, f.track(model.BtcUsd, nil, 3))
+}
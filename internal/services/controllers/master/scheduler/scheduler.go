@@ -0,0 +1,221 @@
+// Package scheduler - package for materializing of recurring monitorings from templates by cron schedules
+package scheduler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/imperiuse/price_monitor/internal/logger"
+	"github.com/imperiuse/price_monitor/internal/logger/field"
+	"github.com/imperiuse/price_monitor/internal/services/controllers"
+	"github.com/imperiuse/price_monitor/internal/services/storage"
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+type (
+	// config - config of scheduler Controller.
+	config struct {
+		intervalPeriodicCheck time.Duration
+		batchSize             uint64
+	}
+
+	// ControllerDaemon - scheduler controller, creates scheduled monitorings (runs) from templates which next run
+	// is due. Missed runs (e.g. while there was no master) are skipped, only the last due run is created.
+	ControllerDaemon struct {
+		*controllers.Base
+
+		config  config
+		storage storage.Storage
+
+		cancelFunc context.CancelFunc
+	}
+)
+
+const name = "monitorings_scheduler"
+
+// MinScheduleInterval - min interval between runs of template (one monitoring is created per run).
+const MinScheduleInterval = time.Minute
+
+var (
+	// ErrScheduleNeverFires - cron schedule has no next run (e.g. 0 0 30 2 *).
+	ErrScheduleNeverFires = errors.New("cron schedule never fires")
+
+	// ErrScheduleTooFrequent - interval between runs of cron schedule is shorter than MinScheduleInterval.
+	ErrScheduleTooFrequent = fmt.Errorf("cron schedule fires more often than every %s", MinScheduleInterval)
+)
+
+// New - constructor of scheduler ControllerDaemon.
+func New(cfg controllers.Config, l *logger.Logger, s storage.Storage) (*ControllerDaemon, error) {
+	c := &ControllerDaemon{
+		Base:       controllers.New(name, l),
+		storage:    s,
+		cancelFunc: func() {},
+	}
+
+	if err := c.parseConfig(cfg); err != nil {
+		return nil, fmt.Errorf("scheduler: c.parseConfig(cfg): %w", err)
+	}
+
+	return c, nil
+}
+
+// Register - register scheduler as master controller.
+func Register(c *ControllerDaemon) controllers.Registration {
+	return controllers.AsMaster(c)
+}
+
+// ParseTemplate - parse cron schedule and period of template. Schedule which never fires (e.g. 0 0 30 2 *) or fires
+// more often than MinScheduleInterval is rejected.
+func ParseTemplate(t model.MonitoringTemplate) (cron.Schedule, time.Duration, error) {
+	schedule, err := cron.ParseStandard(t.Cron)
+	if err != nil {
+		return nil, 0, fmt.Errorf("bad cron expression %q: %w", t.Cron, err)
+	}
+
+	first := schedule.Next(time.Now().UTC())
+	if first.IsZero() {
+		return nil, 0, fmt.Errorf("bad cron expression %q: %w", t.Cron, ErrScheduleNeverFires)
+	}
+
+	if second := schedule.Next(first); !second.IsZero() && second.Sub(first) < MinScheduleInterval {
+		return nil, 0, fmt.Errorf("bad cron expression %q: %w", t.Cron, ErrScheduleTooFrequent)
+	}
+
+	period, err := time.ParseDuration(t.Period)
+	if err != nil {
+		return nil, 0, fmt.Errorf("bad period %q: %w", t.Period, err)
+	}
+
+	return schedule, period, nil
+}
+
+func (c *ControllerDaemon) parseConfig(cfg controllers.Config) error {
+	var err error
+
+	c.config.intervalPeriodicCheck, err = time.ParseDuration(cfg.Master.Scheduler.IntervalPeriodicCheck)
+	if err != nil {
+		return fmt.Errorf("%s: can't parse time.ParseDuration(cfg.Master.Scheduler.IntervalPeriodicCheck): %w",
+			c.Name, err)
+	}
+
+	c.config.batchSize = uint64(cfg.Master.Scheduler.BatchSize)
+
+	policy, err := controllers.NewRestartPolicy(cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.Name, err)
+	}
+
+	c.SetRestartPolicy(policy)
+
+	return nil
+}
+
+// Run - run controller func.
+func (c *ControllerDaemon) Run(ctx context.Context) error {
+	c.SetState(controllers.StateStarting)
+
+	ctx, cancel := context.WithCancel(ctx)
+	c.cancelFunc = cancel
+
+	c.Go(ctx, "materialize", func(ctx context.Context) error {
+		c.Log.Info("[Scheduler] Run")
+		defer c.Log.Info("[Scheduler] Finished")
+
+		t := time.NewTicker(c.config.intervalPeriodicCheck)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-t.C:
+				c.ReportHealth(c.materialize(ctx))
+			}
+		}
+	})
+
+	c.SetState(controllers.StateRunning)
+
+	return nil
+}
+
+// Shutdown - shutdown func.
+func (c *ControllerDaemon) Shutdown(_ context.Context) {
+	c.cancelFunc()
+
+	c.SetState(controllers.StateStopped)
+}
+
+// materialize - create runs of templates which next run is due.
+func (c *ControllerDaemon) materialize(ctx context.Context) error {
+	now := time.Now().UTC()
+
+	templates := make([]model.MonitoringTemplate, 0, c.config.batchSize)
+	if err := c.storage.Connector().Repo(model.MonitoringTemplate{}).Select(ctx,
+		storage.Select("*").Where("NOT paused AND next_run_at <= ?", now).OrderBy("next_run_at").Limit(c.config.batchSize),
+		&templates,
+	); err != nil {
+		c.Log.Error("[Scheduler] err while select due templates", field.Error(err))
+
+		return fmt.Errorf("can't select due templates: %w", err)
+	}
+
+	for _, t := range templates {
+		schedule, period, err := ParseTemplate(t)
+		if err != nil {
+			c.Log.Error("[Scheduler] bad template, pause it", field.ID(t.ID), field.Error(err))
+			c.pause(ctx, t)
+
+			continue
+		}
+
+		runAt, nextRunAt := lastDueRun(schedule, t.NextRunAt, now), schedule.Next(now)
+		if runAt.IsZero() || nextRunAt.IsZero() {
+			c.Log.Error("[Scheduler] schedule of template never fires, pause it", field.ID(t.ID))
+			c.pause(ctx, t)
+
+			continue
+		}
+
+		id, err := storage.MaterializeTemplateRun(ctx, c.storage, t, runAt, period, nextRunAt.UTC())
+		if err != nil {
+			c.Log.Error("[Scheduler] err while materialize run of template", field.ID(t.ID), field.Error(err))
+
+			return fmt.Errorf("can't materialize run of template %d: %w", t.ID, err)
+		}
+
+		if id != 0 {
+			c.Log.Info("[Scheduler] run of template created", field.ID(t.ID), field.Int64("monitoringID", id))
+		}
+	}
+
+	return nil
+}
+
+// pause - pause template which can not be run, otherwise it is selected as due on every check.
+func (c *ControllerDaemon) pause(ctx context.Context, t model.MonitoringTemplate) {
+	if _, err := c.storage.Connector().Repo(t).UpdateCustom(ctx, map[string]any{"paused": true},
+		storage.Eq{"id": t.ID}); err != nil {
+		c.Log.Error("[Scheduler] err while pause template", field.ID(t.ID), field.Error(err))
+	}
+}
+
+// lastDueRun - the latest run of schedule not later than now, starting from due run (missed runs are skipped).
+// Return zero time if due run is zero (schedule never fires).
+func lastDueRun(schedule cron.Schedule, due, now time.Time) time.Time {
+	if due.IsZero() {
+		return time.Time{}
+	}
+
+	run := due
+
+	for next := schedule.Next(run); !next.IsZero() && !next.After(now); next = schedule.Next(next) {
+		run = next
+	}
+
+	return run.UTC()
+}
@@ -0,0 +1,55 @@
+package scheduler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+func TestParseTemplate(t *testing.T) {
+	schedule, period, err := ParseTemplate(model.MonitoringTemplate{Cron: "0 9 * * 1-5", Period: "1h"})
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, period)
+
+	friday := time.Date(2022, 1, 7, 10, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2022, 1, 10, 9, 0, 0, 0, time.UTC), schedule.Next(friday))
+
+	_, _, err = ParseTemplate(model.MonitoringTemplate{Cron: "0 9 * *", Period: "1h"})
+	assert.Error(t, err)
+
+	_, _, err = ParseTemplate(model.MonitoringTemplate{Cron: "@hourly", Period: "bad"})
+	assert.Error(t, err)
+
+	_, _, err = ParseTemplate(model.MonitoringTemplate{Cron: "0 0 30 2 *", Period: "1h"})
+	assert.ErrorIs(t, err, ErrScheduleNeverFires)
+
+	_, _, err = ParseTemplate(model.MonitoringTemplate{Cron: "@every 1s", Period: "1h"})
+	assert.ErrorIs(t, err, ErrScheduleTooFrequent)
+
+	_, _, err = ParseTemplate(model.MonitoringTemplate{Cron: "@every 1m", Period: "1h"})
+	assert.NoError(t, err)
+}
+
+func Test_lastDueRun(t *testing.T) {
+	schedule, _, err := ParseTemplate(model.MonitoringTemplate{Cron: "@hourly", Period: "1h"})
+	require.NoError(t, err)
+
+	due := time.Date(2022, 1, 1, 10, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, due, lastDueRun(schedule, due, due.Add(30*time.Minute)), "no missed runs")
+	assert.Equal(t, due.Add(3*time.Hour), lastDueRun(schedule, due, due.Add(3*time.Hour)),
+		"run exactly at now is due")
+	assert.Equal(t, due.Add(72*time.Hour), lastDueRun(schedule, due, due.Add(72*time.Hour+59*time.Minute)),
+		"missed runs are skipped")
+
+	never, err := cron.ParseStandard("0 0 30 2 *")
+	require.NoError(t, err)
+
+	assert.True(t, lastDueRun(never, time.Time{}, due).IsZero(), "unsatisfiable schedule does not loop")
+	assert.Equal(t, due, lastDueRun(never, due, due.Add(time.Hour)))
+}
@@ -0,0 +1,39 @@
+package controllers
+
+import "go.uber.org/fx"
+
+type (
+	// Registration - result of constructor which contributes controllers to value groups (by node role).
+	// Use it as return value of fx constructor, e.g. `func Register(c *Controller) controllers.Registration`.
+	Registration struct {
+		fx.Out
+
+		General []DaemonController `group:"general_controllers,flatten"` // run on every node
+		Master  []DaemonController `group:"master_controllers,flatten"`  // run only on master node
+		Slave   []DaemonController `group:"slave_controllers,flatten"`   // run only on not master (follower) node
+	}
+
+	// Groups - all controllers contributed to value groups.
+	Groups struct {
+		fx.In
+
+		General []DaemonController `group:"general_controllers"`
+		Master  []DaemonController `group:"master_controllers"`
+		Slave   []DaemonController `group:"slave_controllers"`
+	}
+)
+
+// AsGeneral - register controllers as general (run on every node).
+func AsGeneral(c ...DaemonController) Registration {
+	return Registration{General: c}
+}
+
+// AsMaster - register controllers as master (run only on master node).
+func AsMaster(c ...DaemonController) Registration {
+	return Registration{Master: c}
+}
+
+// AsSlave - register controllers as slave (run only on not master node).
+func AsSlave(c ...DaemonController) Registration {
+	return Registration{Slave: c}
+}
@@ -1,4 +1,15 @@
 package slave
 
-// Config - Config.
-type Config struct{}
+// Config - config for all slave (run only on not master nodes) controllers.
+type Config struct {
+	Standby struct {
+		// BufferWindow - window of buffered samples (last N seconds), which are flushed on promotion
+		BufferWindow string `yaml:"bufferWindow"`
+
+		// IntervalPeriodicScan - scan interval
+		IntervalPeriodicScan string `yaml:"intervalPeriodicScan"`
+
+		// TimeoutFlush - timeout of flush buffered samples to storage on promotion
+		TimeoutFlush string `yaml:"timeoutFlush"`
+	} `yaml:"standby"`
+}
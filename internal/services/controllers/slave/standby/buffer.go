@@ -0,0 +1,53 @@
+package standby
+
+import (
+	"time"
+
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+// ringBuffer - fixed size ring buffer of price samples (not thread safe).
+type ringBuffer struct {
+	samples []model.Price
+	start   int // index of the oldest sample
+	size    int
+}
+
+func newRingBuffer(capacity int) *ringBuffer {
+	if capacity < 1 {
+		capacity = 1
+	}
+
+	return &ringBuffer{samples: make([]model.Price, capacity)}
+}
+
+// Push - push sample, the oldest sample is overwritten if buffer is full.
+func (b *ringBuffer) Push(p model.Price) {
+	if b.size < len(b.samples) {
+		b.samples[(b.start+b.size)%len(b.samples)] = p
+		b.size++
+
+		return
+	}
+
+	b.samples[b.start] = p
+	b.start = (b.start + 1) % len(b.samples)
+}
+
+// Since - return samples with time not before t (from the oldest to the newest).
+func (b *ringBuffer) Since(t time.Time) []model.Price {
+	r := make([]model.Price, 0, b.size)
+
+	for i := 0; i < b.size; i++ {
+		if p := b.samples[(b.start+i)%len(b.samples)]; !p.Time.Before(t) {
+			r = append(r, p)
+		}
+	}
+
+	return r
+}
+
+// Reset - remove all samples.
+func (b *ringBuffer) Reset() {
+	b.start, b.size = 0, 0
+}
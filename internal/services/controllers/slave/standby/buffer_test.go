@@ -0,0 +1,33 @@
+package standby
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+func TestRingBuffer(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	b := newRingBuffer(3)
+
+	assert.Empty(t, b.Since(now.Add(-time.Hour)))
+
+	for i := 0; i < 5; i++ {
+		b.Push(model.Price{Time: now.Add(time.Duration(i) * time.Second), Price: float64(i)})
+	}
+
+	// only last 3 samples are kept, from the oldest to the newest
+	assert.Equal(t, []model.Price{
+		{Time: now.Add(2 * time.Second), Price: 2},
+		{Time: now.Add(3 * time.Second), Price: 3},
+		{Time: now.Add(4 * time.Second), Price: 4},
+	}, b.Since(now))
+
+	assert.Len(t, b.Since(now.Add(4*time.Second)), 1)
+
+	b.Reset()
+	assert.Empty(t, b.Since(now))
+}
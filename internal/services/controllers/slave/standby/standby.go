@@ -0,0 +1,271 @@
+// Package standby - package for hot-standby price scanning on not master nodes
+package standby
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/imperiuse/price_monitor/internal/logger"
+	"github.com/imperiuse/price_monitor/internal/logger/field"
+	"github.com/imperiuse/price_monitor/internal/services/controllers"
+	"github.com/imperiuse/price_monitor/internal/services/controllers/general/sharder"
+	"github.com/imperiuse/price_monitor/internal/services/controllers/leadership"
+	"github.com/imperiuse/price_monitor/internal/services/market"
+	"github.com/imperiuse/price_monitor/internal/services/storage"
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+type (
+	// config - config of standby Controller.
+	config struct {
+		enabled              bool
+		bufferWindow         time.Duration
+		intervalPeriodicScan time.Duration
+		timeoutFlush         time.Duration
+	}
+
+	Currency = model.CurrencyCode
+
+	// ControllerDaemon - hot-standby controller. It scans prices into in-memory ring buffers while node is not
+	// a master and flushes last bufferWindow of samples to storage on promotion (leadership event).
+	ControllerDaemon struct {
+		*controllers.Base
+
+		config  config
+		storage storage.Storage
+		market  market.Market
+		bus     *leadership.Bus
+
+		mu             sync.Mutex // protect buffers
+		buffers        map[Currency]*ringBuffer
+		cancelScanFunc context.CancelFunc
+		listenOnce     sync.Once
+	}
+)
+
+const name = "standby_scanner"
+
+// New - constructor of standby ControllerDaemon.
+func New(
+	cfg controllers.Config,
+	l *logger.Logger,
+	s storage.Storage,
+	m market.Market,
+	bus *leadership.Bus,
+) (*ControllerDaemon, error) {
+	c := &ControllerDaemon{
+		Base:           controllers.New(name, l),
+		storage:        s,
+		market:         m,
+		bus:            bus,
+		buffers:        map[Currency]*ringBuffer{},
+		cancelScanFunc: func() {},
+	}
+
+	if err := c.parseConfig(cfg); err != nil {
+		return nil, fmt.Errorf("standby: c.parseConfig(cfg): %w", err)
+	}
+
+	capacity := int(c.config.bufferWindow/c.config.intervalPeriodicScan) + 1
+	for _, currency := range model.Currencies {
+		c.buffers[currency] = newRingBuffer(capacity)
+	}
+
+	return c, nil
+}
+
+func (c *ControllerDaemon) parseConfig(cfg controllers.Config) error {
+	var err error
+
+	c.config.enabled = cfg.IsEnabled(name)
+
+	c.config.bufferWindow, err = time.ParseDuration(cfg.Slave.Standby.BufferWindow)
+	if err != nil {
+		return fmt.Errorf("%s: can't parse time.ParseDuration(cfg.Slave.Standby.BufferWindow): %w", c.Name, err)
+	}
+
+	c.config.intervalPeriodicScan, err = time.ParseDuration(cfg.Slave.Standby.IntervalPeriodicScan)
+	if err != nil {
+		return fmt.Errorf("%s: can't parse time.ParseDuration(cfg.Slave.Standby.IntervalPeriodicScan): %w",
+			c.Name, err)
+	}
+
+	c.config.timeoutFlush, err = time.ParseDuration(cfg.Slave.Standby.TimeoutFlush)
+	if err != nil {
+		return fmt.Errorf("%s: can't parse time.ParseDuration(cfg.Slave.Standby.TimeoutFlush): %w", c.Name, err)
+	}
+
+	policy, err := controllers.NewRestartPolicy(cfg)
+	if err != nil {
+		return fmt.Errorf("%s: %w", c.Name, err)
+	}
+
+	c.SetRestartPolicy(policy)
+
+	return nil
+}
+
+// Register - register standby as slave controller. In sharded mode every node persists prices of own shards,
+// so hot-standby is not needed.
+func Register(cfg controllers.Config, c *ControllerDaemon) controllers.Registration {
+	if cfg.IsEnabled(sharder.Name) {
+		return controllers.Registration{}
+	}
+
+	return controllers.AsSlave(c)
+}
+
+// IsEnabled - is hot-standby mode enabled.
+func (c *ControllerDaemon) IsEnabled() bool {
+	return c.config.enabled
+}
+
+// Run - run controller func (start scanning into buffers).
+func (c *ControllerDaemon) Run(ctx context.Context) error {
+	if !c.config.enabled {
+		return nil
+	}
+
+	c.SetState(controllers.StateStarting)
+
+	// listen leadership events during all life of app, not only while node is not a master
+	c.listenOnce.Do(func() {
+		events := c.bus.Subscribe()
+
+		c.Go(ctx, "listen_leadership", func(ctx context.Context) error {
+			c.listenLeadership(ctx, events)
+
+			return nil
+		})
+	})
+
+	scanCtx, cancel := context.WithCancel(ctx)
+	c.cancelScanFunc = cancel
+
+	c.Go(scanCtx, "scan", func(ctx context.Context) error {
+		c.Log.Info("[Standby] Run")
+		defer c.Log.Info("[Standby] Finished")
+
+		t := time.NewTicker(c.config.intervalPeriodicScan)
+		defer t.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-t.C:
+				c.scan(ctx)
+			}
+		}
+	})
+
+	c.SetState(controllers.StateRunning)
+
+	return nil
+}
+
+// Shutdown - shutdown func (stop scanning, buffers are kept for flush on promotion).
+func (c *ControllerDaemon) Shutdown(_ context.Context) {
+	c.cancelScanFunc()
+
+	c.SetState(controllers.StateStopped)
+}
+
+func (c *ControllerDaemon) scan(ctx context.Context) {
+	var health error
+
+	defer func() { c.ReportHealth(health) }()
+
+	for _, currency := range model.Currencies {
+		t, price, err := c.market.GetActualPrice(ctx, currency)
+		if err != nil {
+			c.Log.Error("[Standby] err while get actual price", field.String("currency", currency), field.Error(err))
+			health = fmt.Errorf("can't get actual price of %s: %w", currency, err)
+
+			continue
+		}
+
+		c.mu.Lock()
+		c.buffers[currency].Push(model.Price{Time: t.Round(1000 * time.Millisecond), Price: price})
+		c.mu.Unlock()
+	}
+}
+
+func (c *ControllerDaemon) listenLeadership(ctx context.Context, events <-chan leadership.Event) {
+	isLeader := false
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case e := <-events:
+			if e.IsLeader && !isLeader {
+				flushCtx, cancel := context.WithTimeout(ctx, c.config.timeoutFlush)
+				c.flush(flushCtx, e.Time)
+				cancel()
+			}
+
+			isLeader = e.IsLeader
+		}
+	}
+}
+
+// flush - persist buffered samples of last bufferWindow, which are not stored yet.
+func (c *ControllerDaemon) flush(ctx context.Context, promotedAt time.Time) {
+	for _, currency := range model.Currencies {
+		c.mu.Lock()
+		samples := c.buffers[currency].Since(promotedAt.Add(-c.config.bufferWindow))
+		c.buffers[currency].Reset()
+		c.mu.Unlock()
+
+		if len(samples) == 0 {
+			continue
+		}
+
+		cnt, err := c.flushSamples(ctx, currency, samples)
+		if err != nil {
+			c.Log.Error("[Standby] err while flush samples", field.String("currency", currency), field.Error(err))
+
+			continue
+		}
+
+		c.Log.Info("[Standby] flushed buffered samples on promotion",
+			field.String("currency", currency), field.Int("cnt", cnt))
+	}
+}
+
+func (c *ControllerDaemon) flushSamples(ctx context.Context, currency Currency, samples []model.Price) (int, error) {
+	repo := c.storage.Connector().RepoByName(model.PriceTableNameGetterFunc(currency))
+
+	stored := make([]model.Price, 0, len(samples))
+	if err := repo.Select(ctx,
+		storage.Select("time, price").Where("time >= ?", samples[0].Time),
+		&stored,
+	); err != nil {
+		return 0, fmt.Errorf("can not select stored prices: %w", err)
+	}
+
+	storedTimes := make(map[int64]struct{}, len(stored)) // unix nano (time.Time can't be compared by ==)
+	for _, p := range stored {
+		storedTimes[p.Time.UnixNano()] = struct{}{}
+	}
+
+	cnt := 0
+
+	for _, p := range samples {
+		if _, found := storedTimes[p.Time.UnixNano()]; found {
+			continue
+		}
+
+		if _, err := repo.Insert(ctx, []string{"time", "price"}, []any{p.Time, p.Price}); err != nil {
+			return cnt, fmt.Errorf("can not insert buffered price: %w", err)
+		}
+
+		cnt++
+	}
+
+	return cnt, nil
+}
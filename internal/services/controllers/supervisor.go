@@ -0,0 +1,153 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/imperiuse/price_monitor/internal/logger/field"
+)
+
+// UnlimitedRestarts - value of RestartPolicy.MaxRestarts for restart worker forever.
+const UnlimitedRestarts = -1
+
+// DefaultRestartPolicy - restart policy of controllers by default.
+var DefaultRestartPolicy = RestartPolicy{
+	MaxRestarts: UnlimitedRestarts,
+	MinBackoff:  time.Second,
+	MaxBackoff:  30 * time.Second,
+}
+
+// ErrWorkerExited - supervised worker returned without error, but its ctx is not done.
+var ErrWorkerExited = errors.New("worker exited unexpectedly")
+
+type (
+	// RestartPolicy - restart policy of supervised goroutines (workers) of controller.
+	RestartPolicy struct {
+		MaxRestarts int           // max cnt of restarts in a row (0 - never restart, UnlimitedRestarts - forever)
+		MinBackoff  time.Duration // delay before first restart, it's doubled for every next restart in a row
+		MaxBackoff  time.Duration // max delay before restart, worker which works longer is considered as recovered
+	}
+
+	// Worker - long-lived func of controller, which should block until ctx is done.
+	Worker = func(ctx context.Context) error
+)
+
+// SetRestartPolicy - set restart policy for supervised goroutines of controller.
+func (b *Base) SetRestartPolicy(p RestartPolicy) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.restartPolicy = p
+}
+
+// Go - run worker in supervised goroutine: panic is recovered, exited (failed) worker is restarted according to
+// restart policy with exponential backoff, controller is degraded while worker is failed.
+func (b *Base) Go(ctx context.Context, name string, w Worker) {
+	go b.supervise(ctx, name, w)
+}
+
+func (b *Base) supervise(ctx context.Context, name string, w Worker) {
+	b.mu.RLock()
+	policy := b.restartPolicy
+	b.mu.RUnlock()
+
+	backoff := policy.MinBackoff
+	restarts := 0
+
+	for {
+		startedAt := time.Now()
+
+		err := b.runSafe(ctx, name, w)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err == nil {
+			err = ErrWorkerExited
+		}
+
+		b.Log.Error("[Supervisor] worker failed", field.String("worker", name), field.Error(err))
+		b.setWorkerFailed(name, err)
+
+		if time.Since(startedAt) > policy.MaxBackoff { // worker worked long enough, so it's not a restart in a row
+			backoff, restarts = policy.MinBackoff, 0
+		}
+
+		if policy.MaxRestarts != UnlimitedRestarts && restarts >= policy.MaxRestarts {
+			b.Log.Error("[Supervisor] worker won't be restarted anymore", field.String("worker", name),
+				field.Int("restarts", restarts))
+
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+
+		restarts++
+		backoff *= 2
+		if backoff > policy.MaxBackoff {
+			backoff = policy.MaxBackoff
+		}
+
+		b.Log.Warn("[Supervisor] restart worker", field.String("worker", name), field.Int("restarts", restarts))
+		b.setWorkerFailed(name, nil)
+	}
+}
+
+// runSafe - run worker and convert panic to error.
+func (b *Base) runSafe(ctx context.Context, name string, w Worker) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			b.Log.Error("[Supervisor] worker panic", field.String("worker", name),
+				field.Panic(r), field.Stack(string(debug.Stack())))
+
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+
+	return w(ctx)
+}
+
+func (b *Base) setWorkerFailed(name string, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		delete(b.failedWorkers, name)
+
+		return
+	}
+
+	b.failedWorkers[name] = err
+}
+
+// NewRestartPolicy - create restart policy from controllers config (DefaultRestartPolicy values for empty fields).
+func NewRestartPolicy(cfg Config) (RestartPolicy, error) {
+	var err error
+
+	p := DefaultRestartPolicy
+
+	if cfg.Supervisor.MaxRestarts != nil {
+		p.MaxRestarts = *cfg.Supervisor.MaxRestarts
+	}
+
+	if cfg.Supervisor.MinBackoff != "" {
+		if p.MinBackoff, err = time.ParseDuration(cfg.Supervisor.MinBackoff); err != nil {
+			return p, fmt.Errorf("can't parse time.ParseDuration(cfg.Supervisor.MinBackoff): %w", err)
+		}
+	}
+
+	if cfg.Supervisor.MaxBackoff != "" {
+		if p.MaxBackoff, err = time.ParseDuration(cfg.Supervisor.MaxBackoff); err != nil {
+			return p, fmt.Errorf("can't parse time.ParseDuration(cfg.Supervisor.MaxBackoff): %w", err)
+		}
+	}
+
+	return p, nil
+}
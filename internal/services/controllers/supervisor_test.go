@@ -0,0 +1,96 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imperiuse/price_monitor/internal/logger"
+)
+
+func TestBase_Go_RestartAfterPanic(t *testing.T) {
+	b := New("test", logger.NewNop())
+	b.SetRestartPolicy(RestartPolicy{MaxRestarts: UnlimitedRestarts, MinBackoff: time.Millisecond, MaxBackoff: time.Second})
+	b.SetState(StateRunning)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var runs int64
+
+	b.Go(ctx, "worker", func(ctx context.Context) error {
+		if atomic.AddInt64(&runs, 1) < 3 {
+			panic("boom")
+		}
+
+		<-ctx.Done()
+
+		return nil
+	})
+
+	require.Eventually(t, func() bool { return atomic.LoadInt64(&runs) == 3 }, time.Second, time.Millisecond)
+	require.Eventually(t, func() bool { return b.Status().State == StateRunning }, time.Second, time.Millisecond)
+}
+
+func TestBase_Go_MaxRestarts(t *testing.T) {
+	b := New("test", logger.NewNop())
+	b.SetRestartPolicy(RestartPolicy{MaxRestarts: 1, MinBackoff: time.Millisecond, MaxBackoff: time.Second})
+	b.SetState(StateRunning)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var runs int64
+
+	b.Go(ctx, "worker", func(ctx context.Context) error {
+		atomic.AddInt64(&runs, 1)
+
+		return errors.New("failed")
+	})
+
+	require.Eventually(t, func() bool { return atomic.LoadInt64(&runs) == 2 }, time.Second, time.Millisecond)
+	require.Eventually(t, func() bool { return b.Status().State == StateDegraded }, time.Second, time.Millisecond)
+
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int64(2), atomic.LoadInt64(&runs))
+	assert.Contains(t, b.Status().Health, "worker worker failed: failed")
+
+	b.SetState(StateStopped)
+	assert.Equal(t, Status{Name: "test", State: StateStopped}, b.Status())
+}
+
+func TestBase_Status_Health(t *testing.T) {
+	b := New("test", logger.NewNop())
+	assert.Equal(t, StateStopped, b.Status().State)
+
+	b.SetState(StateRunning)
+	b.ReportHealth(errors.New("no connection"))
+	assert.Equal(t, Status{Name: "test", State: StateDegraded, Health: "no connection"}, b.Status())
+
+	b.ReportHealth(nil)
+	assert.Equal(t, Status{Name: "test", State: StateRunning}, b.Status())
+}
+
+func TestNewRestartPolicy(t *testing.T) {
+	p, err := NewRestartPolicy(Config{})
+	require.NoError(t, err)
+	assert.Equal(t, DefaultRestartPolicy, p)
+
+	maxRestarts := 3
+	cfg := Config{}
+	cfg.Supervisor.MaxRestarts = &maxRestarts
+	cfg.Supervisor.MinBackoff = "100ms"
+
+	p, err = NewRestartPolicy(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, RestartPolicy{MaxRestarts: 3, MinBackoff: 100 * time.Millisecond, MaxBackoff: 30 * time.Second}, p)
+
+	cfg.Supervisor.MaxBackoff = "bad"
+	_, err = NewRestartPolicy(cfg)
+	assert.Error(t, err)
+}
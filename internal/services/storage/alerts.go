@@ -0,0 +1,90 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+// AlertRulesOf - all alert rules of currency.
+func AlertRulesOf(ctx context.Context, s Storage, currency model.CurrencyCode) ([]model.AlertRule, error) {
+	rules := make([]model.AlertRule, 0)
+	if err := s.Connector().Repo(model.AlertRule{}).Select(ctx,
+		Select("*").Where(fmt.Sprintf("currency_id = (SELECT id FROM %s WHERE currency_code = ?)",
+			model.Currency{}.Repo()), currency).OrderBy("id"),
+		&rules,
+	); err != nil {
+		return nil, fmt.Errorf("can not get alert rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// SetAlertRuleArmed - set armed state of rule if it is still "was" (rule can be evaluated concurrently by samples
+// of several scan workers). Return false if state has been changed by other.
+func SetAlertRuleArmed(ctx context.Context, s Storage, id model.Identity, was *bool, armed bool) (bool, error) {
+	res, err := s.PureSqlxDB().ExecContext(ctx, fmt.Sprintf(
+		`UPDATE %s SET armed = $2 WHERE id = $1 AND armed IS NOT DISTINCT FROM $3`, model.AlertRule{}.Repo()),
+		id, armed, was)
+	if err != nil {
+		return false, fmt.Errorf("can not set armed state of alert rule: %w", err)
+	}
+
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("can not set armed state of alert rule: %w", err)
+	}
+
+	return cnt == 1, nil
+}
+
+// FireAlert - disarm armed rule of event, move its last_fired_at and store event in one statement.
+// Return id of stored event or 0 if rule is not armed anymore (it has been fired concurrently or deleted).
+func FireAlert(ctx context.Context, s Storage, e model.AlertEvent) (model.Identity, error) {
+	var id model.Identity
+
+	err := s.PureSqlxDB().QueryRowxContext(ctx, fmt.Sprintf(`
+WITH r AS (
+	UPDATE %[1]s SET armed = FALSE, last_fired_at = $2
+	WHERE id = $1 AND armed
+	RETURNING id, currency_id
+)
+INSERT INTO %[2]s(rule_id, currency_id, fired_at, price, value, message)
+SELECT id, currency_id, $2, $3, $4, $5 FROM r
+RETURNING id`,
+		model.AlertRule{}.Repo(), model.AlertEvent{}.Repo()),
+		e.RuleID, e.FiredAt, e.Price, e.Value, e.Message,
+	).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+
+		return 0, fmt.Errorf("can not fire alert: %w", err)
+	}
+
+	return id, nil
+}
+
+// PriceRange - min and max prices in [from, to], false if there are no prices.
+func PriceRange(
+	ctx context.Context,
+	s Storage,
+	currency model.CurrencyCode,
+	from, to time.Time,
+) (float64, float64, bool, error) {
+	var minPrice, maxPrice sql.NullFloat64
+
+	if err := s.PureSqlxDB().QueryRowxContext(ctx, fmt.Sprintf(
+		`SELECT min(price), max(price) FROM %s WHERE time >= $1 AND time <= $2`,
+		model.PriceTableNameGetterFunc(currency)), from, to,
+	).Scan(&minPrice, &maxPrice); err != nil {
+		return 0, 0, false, fmt.Errorf("can not get range of prices: %w", err)
+	}
+
+	return minPrice.Float64, maxPrice.Float64, minPrice.Valid, nil
+}
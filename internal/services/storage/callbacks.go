@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+// DueCallbacks - at most limit not delivered callbacks of finished monitorings, which next attempt has come.
+func DueCallbacks(ctx context.Context, s Storage, now time.Time, limit uint64) ([]model.MonitoringCallback, error) {
+	callbacks := make([]model.MonitoringCallback, 0, limit)
+
+	if err := s.PureSqlxDB().SelectContext(ctx, &callbacks, fmt.Sprintf(`
+SELECT cb.* FROM %[1]s cb JOIN %[2]s m ON m.id = cb.monitoring_id
+WHERE cb.delivered_at IS NULL AND cb.failed_at IS NULL AND cb.next_attempt_at <= $1 AND m.status IN ($2, $3, $4)
+ORDER BY cb.next_attempt_at LIMIT $5`, model.MonitoringCallback{}.Repo(), model.Monitoring{}.Repo()),
+		now, model.StatusCompleted, model.StatusFailed, model.StatusCancelled, limit,
+	); err != nil {
+		return nil, fmt.Errorf("can not get due callbacks: %w", err)
+	}
+
+	return callbacks, nil
+}
+
+// SaveCallbackAttempt - store result of delivery attempt of callback (payload, attempts, next attempt, delivery).
+func SaveCallbackAttempt(ctx context.Context, s Storage, cb model.MonitoringCallback) error {
+	if _, err := s.Connector().Repo(cb).UpdateCustom(ctx, map[string]any{
+		"payload":         cb.Payload,
+		"attempts":        cb.Attempts,
+		"next_attempt_at": cb.NextAttemptAt,
+		"last_error":      cb.LastError,
+		"delivered_at":    cb.DeliveredAt,
+		"failed_at":       cb.FailedAt,
+	}, Eq{"id": cb.ID}); err != nil {
+		return fmt.Errorf("can not save attempt of callback: %w", err)
+	}
+
+	return nil
+}
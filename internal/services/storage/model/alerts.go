@@ -0,0 +1,56 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// Kinds of alert rules.
+const (
+	AlertCrossAbove AlertKind = "cross_above" // price crosses above threshold
+	AlertCrossBelow AlertKind = "cross_below" // price crosses below threshold
+	AlertChange     AlertKind = "change"      // price moves (up or down) more than threshold percent within window
+)
+
+type (
+	// AlertKind - kind of alert rule.
+	AlertKind = string
+
+	// Channels - names of notification channels of alert rule, stored as jsonb.
+	Channels []string
+)
+
+// Value - impl driver.Valuer.
+func (ch Channels) Value() (driver.Value, error) {
+	if ch == nil {
+		return "[]", nil
+	}
+
+	data, err := json.Marshal([]string(ch))
+	if err != nil {
+		return nil, fmt.Errorf("can not marshal channels: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// Scan - impl sql.Scanner.
+func (ch *Channels) Scan(src any) error {
+	var data []byte
+
+	switch v := src.(type) {
+	case nil:
+		*ch = Channels{}
+
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("can not scan %T into Channels", src)
+	}
+
+	return json.Unmarshal(data, (*[]string)(ch))
+}
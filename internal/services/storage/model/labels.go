@@ -0,0 +1,74 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const maxLabels = 16
+
+// ErrBadLabel - label is not in key:value format or key/value has bad symbols.
+var ErrBadLabel = errors.New("bad label")
+
+var labelRe = regexp.MustCompile(`^[a-zA-Z0-9_.\-]{1,64}$`)
+
+// Labels - labels of monitoring (key -> value), stored as jsonb.
+type Labels map[string]string
+
+// ParseLabels - parse labels from "key:value" strings.
+func ParseLabels(raw []string) (Labels, error) {
+	if len(raw) > maxLabels {
+		return nil, fmt.Errorf("%w: too many labels (max %d)", ErrBadLabel, maxLabels)
+	}
+
+	labels := make(Labels, len(raw))
+
+	for _, l := range raw {
+		k, v, ok := strings.Cut(l, ":")
+		if !ok || !labelRe.MatchString(k) || !labelRe.MatchString(v) {
+			return nil, fmt.Errorf("%w: %q (expected key:value)", ErrBadLabel, l)
+		}
+
+		labels[k] = v
+	}
+
+	return labels, nil
+}
+
+// Value - impl driver.Valuer.
+func (l Labels) Value() (driver.Value, error) {
+	if l == nil {
+		return "{}", nil
+	}
+
+	data, err := json.Marshal(map[string]string(l))
+	if err != nil {
+		return nil, fmt.Errorf("can not marshal labels: %w", err)
+	}
+
+	return string(data), nil
+}
+
+// Scan - impl sql.Scanner.
+func (l *Labels) Scan(src any) error {
+	var data []byte
+
+	switch v := src.(type) {
+	case nil:
+		*l = Labels{}
+
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("can not scan %T into Labels", src)
+	}
+
+	return json.Unmarshal(data, (*map[string]string)(l))
+}
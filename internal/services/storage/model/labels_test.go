@@ -0,0 +1,35 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLabels(t *testing.T) {
+	labels, err := ParseLabels([]string{"env:prod", "team:pricing", "env:stage"})
+	require.NoError(t, err)
+	assert.Equal(t, Labels{"env": "stage", "team": "pricing"}, labels)
+
+	for _, bad := range []string{"env", "env:", ":prod", "env:pr od", "e'nv:prod"} {
+		_, err = ParseLabels([]string{bad})
+		assert.ErrorIs(t, err, ErrBadLabel, bad)
+	}
+}
+
+func TestLabels_ValueScan(t *testing.T) {
+	v, err := Labels(nil).Value()
+	require.NoError(t, err)
+	assert.Equal(t, "{}", v)
+
+	v, err = Labels{"env": "prod"}.Value()
+	require.NoError(t, err)
+
+	var l Labels
+	require.NoError(t, l.Scan([]byte(v.(string))))
+	assert.Equal(t, Labels{"env": "prod"}, l)
+
+	require.NoError(t, l.Scan(nil))
+	assert.Equal(t, Labels{}, l)
+}
@@ -13,7 +13,13 @@ var (
 	AllDTO = []any{
 		Currency{},
 		Monitoring{},
+		MonitoringTransition{},
+		MonitoringChange{},
+		MonitoringTemplate{},
 		Price{},
+		AlertRule{},
+		AlertEvent{},
+		MonitoringCallback{},
 	}
 )
 
@@ -21,10 +27,27 @@ const (
 	BtcUsd CurrencyCode = "BTCUSD"
 )
 
+// Currencies - all supported currencies (prices of them are scanned).
+var Currencies = []CurrencyCode{BtcUsd}
+
+// MonitoringsArchiveTable - table of archived (expired and removed by cleaner) monitorings.
+const MonitoringsArchiveTable Table = "monitorings_archive"
+
 var PriceTableNameGetterFunc = func(code CurrencyCode) Table {
 	return fmt.Sprintf("%s_prices", strings.ToLower(code))
 }
 
+// CandleIntervals - intervals of candles materialized by continuous aggregates (interval -> suffix of view).
+var CandleIntervals = map[time.Duration]string{
+	time.Minute:    "1m",
+	time.Hour:      "1h",
+	24 * time.Hour: "1d",
+}
+
+var CandlesViewNameGetterFunc = func(code CurrencyCode, suffix string) Table {
+	return fmt.Sprintf("%s_candles_%s", strings.ToLower(code), suffix)
+}
+
 type (
 	// Identity - identity
 	Identity = int64
@@ -48,6 +71,16 @@ type (
 		Price float64   `db:"price" orm_use_in:"select,create" json:"price"`
 	}
 
+	// Candle - dto for <CURRENCY_CODE>_candles_<INTERVAL> views (OHLC of prices in bucket)
+	Candle struct {
+		Time    time.Time `db:"bucket" json:"time"`
+		Open    float64   `db:"open" json:"open"`
+		High    float64   `db:"high" json:"high"`
+		Low     float64   `db:"low" json:"low"`
+		Close   float64   `db:"close" json:"close"`
+		Samples int64     `db:"samples" json:"samples"`
+	}
+
 	// Monitoring - dto for monitoring price obj
 	Monitoring struct {
 		ID         Identity  `db:"id" orm_use_in:"select" json:"id"`
@@ -56,7 +89,96 @@ type (
 		ExpiredAt  time.Time `db:"expired_at" orm_use_in:"select,create" json:"expired_at"`
 		Frequency  string    `db:"frequency" orm_use_in:"select,create" json:"frequency"`
 		CurrencyID Identity  `db:"currency_id" orm_use_in:"select,create" json:"currency_id"`
-		_          any       `orm_table_name:"monitorings"`
+
+		Status          MonitoringStatus `db:"status" orm_use_in:"select,create" json:"status"`
+		StatusChangedAt time.Time        `db:"status_changed_at" orm_use_in:"select,create" json:"status_changed_at"`
+
+		TemplateID *Identity `db:"template_id" orm_use_in:"select,create" json:"template_id,omitempty"` // nil - not a run of template
+		Labels     Labels    `db:"labels" orm_use_in:"select,create" json:"labels"`
+
+		CallbackURL string `db:"callback_url" orm_use_in:"select,create" json:"callback_url,omitempty"` // "" - no callback
+
+		_ any `orm_table_name:"monitorings"`
+	}
+
+	// MonitoringTransition - dto for transition of monitoring between lifecycle statuses
+	MonitoringTransition struct {
+		ID           Identity         `db:"id" orm_use_in:"select" json:"id"`
+		MonitoringID Identity         `db:"monitoring_id" orm_use_in:"select,create" json:"monitoring_id"`
+		FromStatus   MonitoringStatus `db:"from_status" orm_use_in:"select,create" json:"from_status"`
+		ToStatus     MonitoringStatus `db:"to_status" orm_use_in:"select,create" json:"to_status"`
+		Reason       string           `db:"reason" orm_use_in:"select,create" json:"reason"`
+		CreatedAt    time.Time        `db:"created_at" orm_use_in:"select,create" json:"created_at"`
+		_            any              `orm_table_name:"monitoring_transitions"`
+	}
+
+	// MonitoringTemplate - dto for template of recurring monitorings
+	MonitoringTemplate struct {
+		ID         Identity   `db:"id" orm_use_in:"select" json:"id"`
+		CreatedAt  time.Time  `db:"created_at" orm_use_in:"select,create" json:"created_at"`
+		Cron       string     `db:"cron" orm_use_in:"select,create" json:"cron"`
+		Period     string     `db:"period" orm_use_in:"select,create" json:"period"`
+		Frequency  string     `db:"frequency" orm_use_in:"select,create" json:"frequency"`
+		CurrencyID Identity   `db:"currency_id" orm_use_in:"select,create" json:"currency_id"`
+		Paused     bool       `db:"paused" orm_use_in:"select,create" json:"paused"`
+		NextRunAt  time.Time  `db:"next_run_at" orm_use_in:"select,create" json:"next_run_at"`
+		LastRunAt  *time.Time `db:"last_run_at" orm_use_in:"select" json:"last_run_at"`
+		_          any        `orm_table_name:"monitoring_templates"`
+	}
+
+	// MonitoringChange - dto for change of monitoring (state of monitoring after change)
+	MonitoringChange struct {
+		ID            Identity  `db:"id" orm_use_in:"select" json:"id"`
+		MonitoringID  Identity  `db:"monitoring_id" orm_use_in:"select,create" json:"monitoring_id"`
+		CreatedAt     time.Time `db:"created_at" orm_use_in:"select,create" json:"created_at"`
+		StartedAt     time.Time `db:"started_at" orm_use_in:"select,create" json:"started_at"`
+		ExpiredAt     time.Time `db:"expired_at" orm_use_in:"select,create" json:"expired_at"`
+		Frequency     string    `db:"frequency" orm_use_in:"select,create" json:"frequency"`
+		EffectiveFrom time.Time `db:"effective_from" orm_use_in:"select,create" json:"effective_from"`
+		_             any       `orm_table_name:"monitoring_changes"`
+	}
+
+	// MonitoringCallback - dto for outbox of callbacks (delivery of result of monitoring to its callback_url)
+	MonitoringCallback struct {
+		ID            Identity   `db:"id" orm_use_in:"select" json:"id"`
+		MonitoringID  Identity   `db:"monitoring_id" orm_use_in:"select,create" json:"monitoring_id"`
+		URL           string     `db:"url" orm_use_in:"select,create" json:"url"`
+		CreatedAt     time.Time  `db:"created_at" orm_use_in:"select,create" json:"created_at"`
+		Payload       *string    `db:"payload" orm_use_in:"select" json:"payload"` // nil - not attempted yet
+		Attempts      int        `db:"attempts" orm_use_in:"select" json:"attempts"`
+		NextAttemptAt time.Time  `db:"next_attempt_at" orm_use_in:"select,create" json:"next_attempt_at"`
+		LastError     string     `db:"last_error" orm_use_in:"select" json:"last_error"`
+		DeliveredAt   *time.Time `db:"delivered_at" orm_use_in:"select" json:"delivered_at"`
+		FailedAt      *time.Time `db:"failed_at" orm_use_in:"select" json:"failed_at"` // max attempts were made
+		_             any        `orm_table_name:"monitoring_callbacks"`
+	}
+
+	// AlertRule - dto for alert rule on prices
+	AlertRule struct {
+		ID          Identity   `db:"id" orm_use_in:"select" json:"id"`
+		CreatedAt   time.Time  `db:"created_at" orm_use_in:"select,create" json:"created_at"`
+		CurrencyID  Identity   `db:"currency_id" orm_use_in:"select,create" json:"currency_id"`
+		Kind        AlertKind  `db:"kind" orm_use_in:"select,create" json:"kind"`
+		Threshold   float64    `db:"threshold" orm_use_in:"select,create" json:"threshold"`   // price or percent
+		Window      string     `db:"move_window" orm_use_in:"select,create" json:"window"`    // only for change
+		Hysteresis  float64    `db:"hysteresis" orm_use_in:"select,create" json:"hysteresis"` // to re-arm rule
+		Cooldown    string     `db:"cooldown" orm_use_in:"select,create" json:"cooldown"`     // between fires
+		Channels    Channels   `db:"channels" orm_use_in:"select,create" json:"channels"`     // from config
+		Armed       *bool      `db:"armed" orm_use_in:"select" json:"armed"`                  // nil - not evaluated
+		LastFiredAt *time.Time `db:"last_fired_at" orm_use_in:"select" json:"last_fired_at"`
+		_           any        `orm_table_name:"alert_rules"`
+	}
+
+	// AlertEvent - dto for fired alert
+	AlertEvent struct {
+		ID         Identity  `db:"id" orm_use_in:"select" json:"id"`
+		RuleID     *Identity `db:"rule_id" orm_use_in:"select,create" json:"rule_id"` // nil - rule was deleted
+		CurrencyID Identity  `db:"currency_id" orm_use_in:"select,create" json:"currency_id"`
+		FiredAt    time.Time `db:"fired_at" orm_use_in:"select,create" json:"fired_at"`
+		Price      float64   `db:"price" orm_use_in:"select,create" json:"price"`
+		Value      float64   `db:"value" orm_use_in:"select,create" json:"value"` // price or percent of move
+		Message    string    `db:"message" orm_use_in:"select,create" json:"message"`
+		_          any       `orm_table_name:"alert_events"`
 	}
 )
 
@@ -70,6 +192,30 @@ func (m Monitoring) Identity() db.ID {
 	return m.ID
 }
 
+func (t MonitoringTransition) Repo() db.Table {
+	return orm.GetTableName(t) // cached
+}
+
+func (t MonitoringTransition) Identity() db.ID {
+	return t.ID
+}
+
+func (ch MonitoringChange) Repo() db.Table {
+	return orm.GetTableName(ch) // cached
+}
+
+func (ch MonitoringChange) Identity() db.ID {
+	return ch.ID
+}
+
+func (t MonitoringTemplate) Repo() db.Table {
+	return orm.GetTableName(t) // cached
+}
+
+func (t MonitoringTemplate) Identity() db.ID {
+	return t.ID
+}
+
 func (c Currency) Repo() db.Table {
 	return orm.GetTableName(c) // cached
 }
@@ -77,3 +223,27 @@ func (c Currency) Repo() db.Table {
 func (c Currency) Identity() db.ID {
 	return c.ID
 }
+
+func (r AlertRule) Repo() db.Table {
+	return orm.GetTableName(r) // cached
+}
+
+func (r AlertRule) Identity() db.ID {
+	return r.ID
+}
+
+func (e AlertEvent) Repo() db.Table {
+	return orm.GetTableName(e) // cached
+}
+
+func (e AlertEvent) Identity() db.ID {
+	return e.ID
+}
+
+func (cb MonitoringCallback) Repo() db.Table {
+	return orm.GetTableName(cb) // cached
+}
+
+func (cb MonitoringCallback) Identity() db.ID {
+	return cb.ID
+}
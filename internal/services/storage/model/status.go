@@ -0,0 +1,48 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Statuses of monitoring lifecycle.
+const (
+	StatusScheduled MonitoringStatus = "scheduled" // created, started_at has not come yet
+	StatusRunning   MonitoringStatus = "running"   // prices are being collected
+	StatusCompleted MonitoringStatus = "completed" // expired, some prices were collected
+	StatusCancelled MonitoringStatus = "cancelled" // cancelled by client before it was finished
+	StatusFailed    MonitoringStatus = "failed"    // expired, no prices were collected
+)
+
+// ErrBadStatusTransition - transition between monitoring statuses is not allowed.
+var ErrBadStatusTransition = errors.New("bad monitoring status transition")
+
+// MonitoringStatus - status of monitoring lifecycle.
+type MonitoringStatus = string
+
+// statusTransitions - state machine of monitoring lifecycle (status -> allowed next statuses).
+var statusTransitions = map[MonitoringStatus][]MonitoringStatus{
+	StatusScheduled: {StatusRunning, StatusCancelled},
+	StatusRunning:   {StatusCompleted, StatusFailed, StatusCancelled},
+	StatusCompleted: nil,
+	StatusCancelled: nil,
+	StatusFailed:    nil,
+}
+
+// ValidateStatusTransition - return ErrBadStatusTransition if monitoring can't go from status to status.
+func ValidateStatusTransition(from, to MonitoringStatus) error {
+	for _, s := range statusTransitions[from] {
+		if s == to {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %q -> %q", ErrBadStatusTransition, from, to)
+}
+
+// IsFinalStatus - is status final (monitoring can't change status anymore).
+func IsFinalStatus(s MonitoringStatus) bool {
+	next, found := statusTransitions[s]
+
+	return found && len(next) == 0
+}
@@ -0,0 +1,29 @@
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateStatusTransition(t *testing.T) {
+	assert.NoError(t, ValidateStatusTransition(StatusScheduled, StatusRunning))
+	assert.NoError(t, ValidateStatusTransition(StatusScheduled, StatusCancelled))
+	assert.NoError(t, ValidateStatusTransition(StatusRunning, StatusCompleted))
+	assert.NoError(t, ValidateStatusTransition(StatusRunning, StatusFailed))
+	assert.NoError(t, ValidateStatusTransition(StatusRunning, StatusCancelled))
+
+	assert.ErrorIs(t, ValidateStatusTransition(StatusScheduled, StatusCompleted), ErrBadStatusTransition)
+	assert.ErrorIs(t, ValidateStatusTransition(StatusCompleted, StatusRunning), ErrBadStatusTransition)
+	assert.ErrorIs(t, ValidateStatusTransition(StatusCancelled, StatusCancelled), ErrBadStatusTransition)
+	assert.ErrorIs(t, ValidateStatusTransition("unknown", StatusRunning), ErrBadStatusTransition)
+}
+
+func TestIsFinalStatus(t *testing.T) {
+	assert.False(t, IsFinalStatus(StatusScheduled))
+	assert.False(t, IsFinalStatus(StatusRunning))
+	assert.True(t, IsFinalStatus(StatusCompleted))
+	assert.True(t, IsFinalStatus(StatusCancelled))
+	assert.True(t, IsFinalStatus(StatusFailed))
+	assert.False(t, IsFinalStatus("unknown"))
+}
@@ -0,0 +1,327 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+// CreateMonitoring - create new monitoring in its initial status and record this "creation" transition and change
+// in one statement. Callback (outbox record) is created too if monitoring has callback url, so monitoring never
+// exists without its callback.
+func CreateMonitoring(ctx context.Context, s Storage, m model.Monitoring, reason string) (int64, error) {
+	m.StatusChangedAt = time.Now().UTC()
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = m.StatusChangedAt
+	}
+
+	if m.Status == "" {
+		m.Status = model.StatusScheduled
+	}
+
+	var id model.Identity
+
+	// callback is delivered as soon as monitoring is finished (next_attempt_at is creation time)
+	if err := s.PureSqlxDB().QueryRowxContext(ctx, fmt.Sprintf(`
+WITH m AS (
+	INSERT INTO %[1]s(created_at, started_at, expired_at, frequency, currency_id, status, status_changed_at,
+		template_id, labels, callback_url)
+	VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	RETURNING id, started_at, expired_at, frequency, status, status_changed_at, callback_url
+), tr AS (
+	INSERT INTO %[2]s(monitoring_id, from_status, to_status, reason, created_at)
+	SELECT id, '', status, $11, status_changed_at FROM m
+), ch AS (
+	INSERT INTO %[3]s(monitoring_id, created_at, started_at, expired_at, frequency, effective_from)
+	SELECT id, status_changed_at, started_at, expired_at, frequency, started_at FROM m
+), cb AS (
+	INSERT INTO %[4]s(monitoring_id, url, created_at, next_attempt_at)
+	SELECT id, callback_url, status_changed_at, status_changed_at FROM m WHERE callback_url <> ''
+)
+SELECT id FROM m`,
+		model.Monitoring{}.Repo(), model.MonitoringTransition{}.Repo(), model.MonitoringChange{}.Repo(),
+		model.MonitoringCallback{}.Repo()),
+		m.CreatedAt, m.StartedAt, m.ExpiredAt, m.Frequency, m.CurrencyID, m.Status, m.StatusChangedAt,
+		m.TemplateID, m.Labels, m.CallbackURL, reason,
+	).Scan(&id); err != nil {
+		return 0, fmt.Errorf("can not create monitoring: %w", err)
+	}
+
+	return id, nil
+}
+
+// ChangeMonitoring - change window and frequency of monitoring and record change in one statement. Monitoring is
+// changed only if it is still in status m.Status, new frequency is effective from effectiveFrom.
+// Return false if monitoring was not changed.
+func ChangeMonitoring(ctx context.Context, s Storage, m model.Monitoring, effectiveFrom time.Time) (bool, error) {
+	res, err := s.PureSqlxDB().ExecContext(ctx, fmt.Sprintf(`
+WITH changed AS (
+	UPDATE %[1]s SET started_at = $3, expired_at = $4, frequency = $5 WHERE id = $1 AND status = $2
+	RETURNING id, started_at, expired_at, frequency
+)
+INSERT INTO %[2]s(monitoring_id, created_at, started_at, expired_at, frequency, effective_from)
+SELECT id, $6, started_at, expired_at, frequency, $7 FROM changed`,
+		model.Monitoring{}.Repo(), model.MonitoringChange{}.Repo()),
+		m.ID, m.Status, m.StartedAt, m.ExpiredAt, m.Frequency, time.Now().UTC(), effectiveFrom,
+	)
+	if err != nil {
+		return false, fmt.Errorf("can not change monitoring: %w", err)
+	}
+
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("res.RowsAffected: %w", err)
+	}
+
+	return cnt == 1, nil
+}
+
+// MonitoringChanges - history of changes of monitoring in order of changes.
+func MonitoringChanges(ctx context.Context, s Storage, id model.Identity) ([]model.MonitoringChange, error) {
+	changes := make([]model.MonitoringChange, 0)
+
+	if err := s.Connector().Repo(model.MonitoringChange{}).Select(ctx,
+		Select("*").Where(Eq{"monitoring_id": id}).OrderBy("id"),
+		&changes,
+	); err != nil {
+		return nil, fmt.Errorf("can not select monitoring changes: %w", err)
+	}
+
+	return changes, nil
+}
+
+// TransitMonitoring - change status of monitoring and record transition in one statement. Status is changed only if
+// monitoring is in from status now, so concurrent transitions are safe. Return false if status was not changed.
+func TransitMonitoring(
+	ctx context.Context,
+	s Storage,
+	id model.Identity,
+	from, to model.MonitoringStatus,
+	reason string,
+) (bool, error) {
+	if err := model.ValidateStatusTransition(from, to); err != nil {
+		return false, err
+	}
+
+	res, err := s.PureSqlxDB().ExecContext(ctx, fmt.Sprintf(`
+WITH changed AS (
+	UPDATE %[1]s SET status = $3, status_changed_at = $5 WHERE id = $1 AND status = $2 RETURNING id
+)
+INSERT INTO %[2]s(monitoring_id, from_status, to_status, reason, created_at)
+SELECT id, $2, $3, $4, $5 FROM changed`, model.Monitoring{}.Repo(), model.MonitoringTransition{}.Repo()),
+		id, from, to, reason, time.Now().UTC(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("can not change status of monitoring: %w", err)
+	}
+
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("res.RowsAffected: %w", err)
+	}
+
+	return cnt == 1, nil
+}
+
+// FinishMonitoring - finish running monitoring at time: truncate expired_at and change status to completed
+// (or failed if no prices were collected), record transition. Return new status, or "" if monitoring is not running.
+func FinishMonitoring(
+	ctx context.Context,
+	s Storage,
+	id model.Identity,
+	currency model.CurrencyCode,
+	at time.Time,
+	reason string,
+) (model.MonitoringStatus, error) {
+	var status model.MonitoringStatus
+
+	err := s.PureSqlxDB().QueryRowxContext(ctx, fmt.Sprintf(`
+WITH changed AS (
+	UPDATE %[1]s m SET
+		expired_at = LEAST(m.expired_at, $5),
+		status = CASE WHEN EXISTS (
+			SELECT 1 FROM %[3]s p WHERE p.time BETWEEN m.started_at AND LEAST(m.expired_at, $5)
+		) THEN $3 ELSE $4 END,
+		status_changed_at = $5
+	WHERE m.id = $1 AND m.status = $2
+	RETURNING m.id, m.status
+)
+INSERT INTO %[2]s(monitoring_id, from_status, to_status, reason, created_at)
+SELECT id, $2, status, $6, $5 FROM changed
+RETURNING to_status`, model.Monitoring{}.Repo(), model.MonitoringTransition{}.Repo(),
+		model.PriceTableNameGetterFunc(currency)),
+		id, model.StatusRunning, model.StatusCompleted, model.StatusFailed, at, reason,
+	).Scan(&status)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", nil
+		}
+
+		return "", fmt.Errorf("can not finish monitoring: %w", err)
+	}
+
+	return status, nil
+}
+
+// Sort columns of monitorings list.
+const (
+	SortByCreatedAt = "created_at"
+	SortByExpiredAt = "expired_at"
+)
+
+var (
+	// ErrBadSort - unknown sort column of monitorings list.
+	ErrBadSort = errors.New("bad sort column")
+
+	// ErrBadCursor - cursor of monitorings list is malformed or was issued for other sort column.
+	ErrBadCursor = errors.New("bad cursor")
+)
+
+// MonitoringsCursor - keyset of monitorings list: sort column, its value and id of the last monitoring of the previous
+// page. Clients get it as opaque string, so pagination goes on even if that monitoring was deleted or changed.
+type MonitoringsCursor struct {
+	SortBy string
+	Sort   time.Time
+	ID     model.Identity
+}
+
+// NewMonitoringsCursor - cursor of page after monitoring m of list sorted by sortBy.
+func NewMonitoringsCursor(m model.Monitoring, sortBy string) MonitoringsCursor {
+	sort := m.CreatedAt
+	if sortBy == SortByExpiredAt {
+		sort = m.ExpiredAt
+	}
+
+	return MonitoringsCursor{SortBy: sortBy, Sort: sort.UTC(), ID: m.ID}
+}
+
+// String - opaque form of cursor ("" - zero cursor, first page).
+func (c MonitoringsCursor) String() string {
+	if c.ID == 0 {
+		return ""
+	}
+
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%s:%d:%d", c.SortBy, c.Sort.UnixNano(), c.ID)))
+}
+
+// ParseMonitoringsCursor - parse opaque form of cursor ("" - zero cursor, first page).
+func ParseMonitoringsCursor(str string) (MonitoringsCursor, error) {
+	if str == "" {
+		return MonitoringsCursor{}, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(str)
+	if err != nil {
+		return MonitoringsCursor{}, fmt.Errorf("%w: %v", ErrBadCursor, err)
+	}
+
+	parts := strings.Split(string(raw), ":")
+	if len(parts) != 3 || (parts[0] != SortByCreatedAt && parts[0] != SortByExpiredAt) {
+		return MonitoringsCursor{}, fmt.Errorf("%w: %q", ErrBadCursor, str)
+	}
+
+	sort, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil {
+		return MonitoringsCursor{}, fmt.Errorf("%w: %v", ErrBadCursor, err)
+	}
+
+	id, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil || id <= 0 {
+		return MonitoringsCursor{}, fmt.Errorf("%w: %q", ErrBadCursor, str)
+	}
+
+	return MonitoringsCursor{SortBy: parts[0], Sort: time.Unix(0, sort).UTC(), ID: id}, nil
+}
+
+// MonitoringsFilter - filter of monitorings list, zero value of field means no filtering by it.
+type MonitoringsFilter struct {
+	CurrencyID model.Identity
+	Statuses   []model.MonitoringStatus
+	From, To   time.Time // window of monitoring overlaps [From, To]
+	Frequency  string
+	Labels     model.Labels // monitoring has all of these labels
+}
+
+// ListMonitorings - one page of at most limit monitorings filtered by filter and sorted by sortBy column (with id as
+// tiebreaker). Keyset pagination: monitorings after cursor (zero - first page).
+func ListMonitorings(
+	ctx context.Context,
+	s Storage,
+	filter MonitoringsFilter,
+	sortBy string,
+	after MonitoringsCursor,
+	limit uint64,
+	desc bool,
+) ([]model.Monitoring, error) {
+	query, err := listMonitoringsQuery(filter, sortBy, after, limit, desc)
+	if err != nil {
+		return nil, err
+	}
+
+	monitorings := make([]model.Monitoring, 0, limit)
+	if err = s.Connector().Repo(model.Monitoring{}).Select(ctx, query, &monitorings); err != nil {
+		return nil, fmt.Errorf("can not select monitorings: %w", err)
+	}
+
+	return monitorings, nil
+}
+
+func listMonitoringsQuery(
+	filter MonitoringsFilter,
+	sortBy string,
+	after MonitoringsCursor,
+	limit uint64,
+	desc bool,
+) (SelectBuilder, error) {
+	if sortBy != SortByCreatedAt && sortBy != SortByExpiredAt {
+		return SelectBuilder{}, fmt.Errorf("%w: %q", ErrBadSort, sortBy)
+	}
+
+	if after.ID != 0 && after.SortBy != sortBy {
+		return SelectBuilder{}, fmt.Errorf("%w: cursor of list sorted by %s", ErrBadCursor, after.SortBy)
+	}
+
+	query := Select("*")
+
+	if filter.CurrencyID != 0 {
+		query = query.Where(Eq{"currency_id": filter.CurrencyID})
+	}
+
+	if len(filter.Statuses) > 0 {
+		query = query.Where(Eq{"status": filter.Statuses})
+	}
+
+	if !filter.From.IsZero() {
+		query = query.Where(GtOrEq{"expired_at": filter.From})
+	}
+
+	if !filter.To.IsZero() {
+		query = query.Where(LtOrEq{"started_at": filter.To})
+	}
+
+	if filter.Frequency != "" {
+		query = query.Where(Eq{"frequency": filter.Frequency})
+	}
+
+	if len(filter.Labels) > 0 {
+		query = query.Where("labels @> ?::jsonb", filter.Labels)
+	}
+
+	op, order := ">", "ASC"
+	if desc {
+		op, order = "<", "DESC"
+	}
+
+	if after.ID != 0 {
+		query = query.Where(fmt.Sprintf("(%s, id) %s (?, ?)", sortBy, op), after.Sort, after.ID)
+	}
+
+	return query.OrderBy(sortBy+" "+order, "id "+order).Limit(limit), nil
+}
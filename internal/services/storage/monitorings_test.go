@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+func Test_listMonitoringsQuery(t *testing.T) {
+	from := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	after := MonitoringsCursor{SortBy: SortByExpiredAt, Sort: from.Add(time.Hour), ID: 5}
+
+	q, err := listMonitoringsQuery(MonitoringsFilter{
+		CurrencyID: 1,
+		Statuses:   []model.MonitoringStatus{model.StatusRunning, model.StatusScheduled},
+		From:       from,
+		Labels:     model.Labels{"env": "prod"},
+	}, SortByExpiredAt, after, 10, true)
+	require.NoError(t, err)
+
+	query, args, err := q.From("monitorings").ToSql()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM monitorings WHERE currency_id = ? AND status IN (?,?) AND expired_at >= ? "+
+		"AND labels @> ?::jsonb AND (expired_at, id) < (?, ?) "+
+		"ORDER BY expired_at DESC, id DESC LIMIT 10", query)
+	assert.Equal(t, []any{after.Sort, after.ID}, args[5:], "keyset is compared with values of cursor")
+
+	_, err = listMonitoringsQuery(MonitoringsFilter{}, SortByCreatedAt, after, 10, true)
+	assert.ErrorIs(t, err, ErrBadCursor, "cursor of list with other sort")
+
+	q, err = listMonitoringsQuery(MonitoringsFilter{}, SortByCreatedAt, MonitoringsCursor{}, 10, false)
+	require.NoError(t, err)
+
+	query, _, err = q.From("monitorings").ToSql()
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT * FROM monitorings ORDER BY created_at ASC, id ASC LIMIT 10", query)
+
+	_, err = listMonitoringsQuery(MonitoringsFilter{}, "id; DROP TABLE monitorings", MonitoringsCursor{}, 1, false)
+	assert.ErrorIs(t, err, ErrBadSort)
+}
+
+func TestMonitoringsCursor(t *testing.T) {
+	m := model.Monitoring{
+		ID:        42,
+		CreatedAt: time.Date(2022, 1, 1, 10, 0, 0, 123456000, time.UTC),
+		ExpiredAt: time.Date(2022, 1, 1, 11, 0, 0, 0, time.UTC),
+	}
+
+	c := NewMonitoringsCursor(m, SortByExpiredAt)
+	assert.Equal(t, MonitoringsCursor{SortBy: SortByExpiredAt, Sort: m.ExpiredAt, ID: 42}, c)
+
+	parsed, err := ParseMonitoringsCursor(c.String())
+	require.NoError(t, err)
+	assert.Equal(t, c, parsed)
+
+	parsed, err = ParseMonitoringsCursor(NewMonitoringsCursor(m, SortByCreatedAt).String())
+	require.NoError(t, err)
+	assert.Equal(t, m.CreatedAt, parsed.Sort)
+
+	parsed, err = ParseMonitoringsCursor("")
+	require.NoError(t, err)
+	assert.Zero(t, parsed, "first page")
+	assert.Empty(t, parsed.String())
+
+	for _, bad := range []string{"5", "!!!", "aWQ6MTo1"} { // aWQ6MTo1 - id:1:5
+		_, err = ParseMonitoringsCursor(bad)
+		assert.ErrorIs(t, err, ErrBadCursor, bad)
+	}
+}
@@ -0,0 +1,69 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v4/stdlib"
+
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+// ChannelMonitoringFinished - channel of notifications about finish of monitorings (payload is id of monitoring),
+// notifications are sent by trigger on monitoring_transitions (@see migrations).
+const ChannelMonitoringFinished = "monitoring_finished"
+
+const timeoutUnlisten = time.Second
+
+// ListenMonitoringsFinished - listen notifications about finish of monitorings on dedicated connection of pool and
+// call onFinished for every of them, onListen is called when listening is started. Block until ctx is done or error.
+func ListenMonitoringsFinished(
+	ctx context.Context,
+	s Storage,
+	onListen func(),
+	onFinished func(id model.Identity),
+) error {
+	conn, err := s.PureSqlxDB().Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("can not get connection for listen: %w", err)
+	}
+	defer conn.Close()
+
+	return conn.Raw(func(driverConn any) error {
+		c, ok := driverConn.(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("can not listen on connection of driver %T", driverConn)
+		}
+
+		pgConn := c.Conn()
+
+		if _, err := pgConn.Exec(ctx, "LISTEN "+ChannelMonitoringFinished); err != nil {
+			return fmt.Errorf("can not listen channel %s: %w", ChannelMonitoringFinished, err)
+		}
+
+		defer func() { // connection goes back to pool, it must not receive notifications anymore
+			ctx, cancel := context.WithTimeout(context.Background(), timeoutUnlisten)
+			defer cancel()
+
+			_, _ = pgConn.Exec(ctx, "UNLISTEN *")
+		}()
+
+		onListen()
+
+		for {
+			n, err := pgConn.WaitForNotification(ctx)
+			if err != nil {
+				return fmt.Errorf("can not wait notification: %w", err)
+			}
+
+			id, err := strconv.ParseInt(n.Payload, 10, 64)
+			if err != nil {
+				return fmt.Errorf("bad payload of notification %q: %w", n.Payload, err)
+			}
+
+			onFinished(id)
+		}
+	})
+}
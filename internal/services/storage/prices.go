@@ -0,0 +1,448 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+// Aggregations of prices in time bucket (one sample per frequency slot).
+const (
+	AggFirst   = "first"   // first price in bucket
+	AggLast    = "last"    // last price in bucket
+	AggAvg     = "avg"     // average price of bucket (time is start of bucket)
+	AggClosest = "closest" // price closest to grid point (start of slot)
+)
+
+// ErrBadAggregation - unknown aggregation of prices.
+var ErrBadAggregation = errors.New("bad aggregation")
+
+type (
+	// PriceSegment - part of window [From, To) downsampled with its own frequency, buckets are aligned to From.
+	PriceSegment struct {
+		From, To time.Time
+		Freq     time.Duration
+	}
+
+	// PriceBucket - downsampled price, Bucket is a grid point of bucket (start of bucket, or its center for closest
+	// aggregation), it's a key of keyset pagination.
+	PriceBucket struct {
+		Bucket time.Time `db:"bucket"`
+		Time   time.Time `db:"time"`
+		Price  float64   `db:"price"`
+	}
+)
+
+// InsertPrice - store price of currency at t, unless price at t is already stored (e.g. by other node, during
+// leadership handover old and new master scan both). Inserts of currency are serialized by advisory lock, so check
+// and insert are atomic. Return false if price at t is already stored.
+func InsertPrice(ctx context.Context, s Storage, currency model.CurrencyCode, t time.Time, price float64) (bool, error) {
+	table := model.PriceTableNameGetterFunc(currency)
+
+	tx, err := s.PureSqlxDB().BeginTxx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("can not begin tx: %w", err)
+	}
+	defer func() { _ = tx.Rollback() }()
+
+	if _, err = tx.ExecContext(ctx, "SELECT pg_advisory_xact_lock(hashtext($1))", table); err != nil {
+		return false, fmt.Errorf("can not lock inserts of prices: %w", err)
+	}
+
+	// check is a separate statement (after lock), so it sees price committed by concurrent insert
+	res, err := tx.ExecContext(ctx, fmt.Sprintf(`
+INSERT INTO %[1]s(time, price) SELECT $1::TIMESTAMP, $2::DOUBLE PRECISION
+WHERE NOT EXISTS (SELECT 1 FROM %[1]s WHERE time = $1::TIMESTAMP)`, table), t, price)
+	if err != nil {
+		return false, fmt.Errorf("can not insert price: %w", err)
+	}
+
+	cnt, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("can not get count of inserted prices: %w", err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return false, fmt.Errorf("can not commit tx: %w", err)
+	}
+
+	return cnt == 1, nil
+}
+
+// DownsamplePrices - one price per bucket of segments frequency by aggregation, computed by TimescaleDB time_bucket.
+// Last segment includes its To. Buckets in (after, before) are returned (zero - no bound), at most limit.
+func DownsamplePrices(
+	ctx context.Context,
+	s Storage,
+	currency model.CurrencyCode,
+	segments []PriceSegment,
+	agg string,
+	after, before time.Time,
+	limit uint64,
+) ([]PriceBucket, error) {
+	query, args, err := downsampleQuery(model.PriceTableNameGetterFunc(currency), segments, agg, after, before, limit,
+		false)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]PriceBucket, 0, limit)
+	if err = s.PureSqlxDB().SelectContext(ctx, &buckets, query, args...); err != nil {
+		return nil, fmt.Errorf("can not downsample prices: %w", err)
+	}
+
+	return buckets, nil
+}
+
+// DownsamplePricesBefore - the same as DownsamplePrices, but the last (at most limit) buckets before "before".
+// Buckets are returned in order of time.
+func DownsamplePricesBefore(
+	ctx context.Context,
+	s Storage,
+	currency model.CurrencyCode,
+	segments []PriceSegment,
+	agg string,
+	before time.Time,
+	limit uint64,
+) ([]PriceBucket, error) {
+	query, args, err := downsampleQuery(model.PriceTableNameGetterFunc(currency), segments, agg, time.Time{}, before,
+		limit, true)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]PriceBucket, 0, limit)
+	if err = s.PureSqlxDB().SelectContext(ctx, &buckets, query, args...); err != nil {
+		return nil, fmt.Errorf("can not downsample prices: %w", err)
+	}
+
+	for i, j := 0, len(buckets)-1; i < j; i, j = i+1, j-1 {
+		buckets[i], buckets[j] = buckets[j], buckets[i]
+	}
+
+	return buckets, nil
+}
+
+func downsampleQuery(
+	table model.Table,
+	segments []PriceSegment,
+	agg string,
+	after, before time.Time,
+	limit uint64,
+	desc bool, // the last buckets (in reverse order)
+) (string, []any, error) {
+	if len(segments) == 0 {
+		return "", nil, errors.New("no segments to downsample")
+	}
+
+	args := make([]any, 0, 4*len(segments)+2)
+	arg := func(v any) string {
+		args = append(args, v)
+
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	parts := make([]string, 0, len(segments))
+
+	for i, seg := range segments {
+		origin := seg.origin(agg)
+
+		toOp := "<"
+		if i == len(segments)-1 {
+			toOp = "<="
+		}
+
+		where := fmt.Sprintf("time >= %s AND time %s %s", arg(seg.From), toOp, arg(seg.To))
+		if !after.IsZero() { // price of bucket with grid point after "after" is not earlier than start of bucket
+			where += " AND time >= " + arg(after.Add(-seg.From.Sub(origin)))
+		}
+
+		if !before.IsZero() { // price of bucket with grid point before "before" is earlier than its end
+			where += " AND time < " + arg(before.Add(seg.Freq))
+		}
+
+		bucketed := fmt.Sprintf(
+			"SELECT time_bucket(make_interval(secs => %s), time, %s::timestamp) AS bucket, time, price FROM %s WHERE %s",
+			arg(seg.Freq.Seconds()), arg(origin), table, where)
+
+		var part string
+
+		switch agg {
+		case AggFirst:
+			part = "SELECT bucket, min(time) AS time, first(price, time) AS price FROM (%s) b GROUP BY bucket"
+		case AggLast:
+			part = "SELECT bucket, max(time) AS time, last(price, time) AS price FROM (%s) b GROUP BY bucket"
+		case AggAvg:
+			part = "SELECT bucket, bucket AS time, avg(price) AS price FROM (%s) b GROUP BY bucket"
+		case AggClosest:
+			half := arg(seg.Freq.Seconds() / 2)
+			part = "SELECT bucket + make_interval(secs => " + half + ") AS bucket, time, price FROM (" +
+				"SELECT DISTINCT ON (bucket) bucket, time, price FROM (%s) b ORDER BY bucket, " +
+				"abs(extract(epoch FROM time - bucket) - " + half + ")) c"
+		default:
+			return "", nil, fmt.Errorf("%w: %q", ErrBadAggregation, agg)
+		}
+
+		parts = append(parts, "("+fmt.Sprintf(part, bucketed)+")")
+	}
+
+	conds := make([]string, 0, 2)
+	if !after.IsZero() {
+		conds = append(conds, "bucket > "+arg(after))
+	}
+
+	if !before.IsZero() {
+		conds = append(conds, "bucket < "+arg(before))
+	}
+
+	query := "SELECT bucket, time, price FROM (" + strings.Join(parts, " UNION ALL ") + ") r"
+	if len(conds) > 0 {
+		query += " WHERE " + strings.Join(conds, " AND ")
+	}
+
+	order := "bucket"
+	if desc {
+		order = "bucket DESC"
+	}
+
+	query += " ORDER BY " + order + " LIMIT " + arg(limit)
+
+	return query, args, nil
+}
+
+// origin - origin of buckets of segment, for closest aggregation buckets are centered on grid points.
+func (seg PriceSegment) origin(agg string) time.Time {
+	if agg == AggClosest {
+		return seg.From.Add(-seg.Freq / 2)
+	}
+
+	return seg.From
+}
+
+// PriceSteps - at most limit grid points of segments after "after" (zero - from the beginning), one point per
+// frequency step, grid points of last segment include its To.
+func PriceSteps(segments []PriceSegment, after time.Time, limit uint64) []time.Time {
+	steps := make([]time.Time, 0, limit)
+
+	for i, seg := range segments {
+		k := time.Duration(0)
+		if after.After(seg.From) || after.Equal(seg.From) {
+			k = after.Sub(seg.From)/seg.Freq + 1
+		}
+
+		for ; uint64(len(steps)) < limit; k++ {
+			at := seg.From.Add(k * seg.Freq)
+			if at.After(seg.To) || (at.Equal(seg.To) && i < len(segments)-1) {
+				break
+			}
+
+			steps = append(steps, at)
+		}
+	}
+
+	return steps
+}
+
+// PriceStepsBefore - at most limit last grid points of segments not later than "before" (in order of time),
+// the same grid points as PriceSteps.
+func PriceStepsBefore(segments []PriceSegment, before time.Time, limit uint64) []time.Time {
+	steps := make([]time.Time, 0, limit)
+
+	for i := len(segments) - 1; i >= 0 && uint64(len(steps)) < limit; i-- {
+		seg := segments[i]
+		if before.Before(seg.From) {
+			continue
+		}
+
+		last := seg.To.Sub(seg.From) / seg.Freq
+		if i < len(segments)-1 && seg.From.Add(last*seg.Freq).Equal(seg.To) { // To is grid point of next segment
+			last--
+		}
+
+		k := before.Sub(seg.From) / seg.Freq
+		if k > last {
+			k = last
+		}
+
+		for ; k >= 0 && uint64(len(steps)) < limit; k-- {
+			steps = append(steps, seg.From.Add(k*seg.Freq))
+		}
+	}
+
+	for i, j := 0, len(steps)-1; i < j; i, j = i+1, j-1 {
+		steps[i], steps[j] = steps[j], steps[i]
+	}
+
+	return steps
+}
+
+// PriceStepOf - grid point of bucket of segments which contains time, false if time is out of segments.
+func PriceStepOf(segments []PriceSegment, agg string, t time.Time) (time.Time, bool) {
+	for i, seg := range segments {
+		if t.Before(seg.From) || t.After(seg.To) || (t.Equal(seg.To) && i < len(segments)-1) {
+			continue
+		}
+
+		origin := seg.origin(agg)
+
+		return seg.From.Add(t.Sub(origin) / seg.Freq * seg.Freq), true
+	}
+
+	return time.Time{}, false
+}
+
+// NearestPriceTime - time of the latest price in [from, to) if desc, otherwise time of the earliest price in
+// [from, to]. Zero time if there are no prices.
+func NearestPriceTime(
+	ctx context.Context,
+	s Storage,
+	currency model.CurrencyCode,
+	from, to time.Time,
+	desc bool,
+) (time.Time, error) {
+	query := `SELECT min(time) FROM %s WHERE time >= $1 AND time <= $2`
+	if desc {
+		query = `SELECT max(time) FROM %s WHERE time >= $1 AND time < $2`
+	}
+
+	var t sql.NullTime
+	if err := s.PureSqlxDB().QueryRowxContext(ctx, fmt.Sprintf(query, model.PriceTableNameGetterFunc(currency)),
+		from, to).Scan(&t); err != nil {
+		return time.Time{}, fmt.Errorf("can not get nearest price time: %w", err)
+	}
+
+	return t.Time, nil
+}
+
+// PriceSummary - statistical summary of prices of window (fields are nil if there are no prices).
+type PriceSummary struct {
+	Samples   int        `db:"samples" json:"samples"`   // cnt of collected prices
+	Expected  int        `db:"-" json:"expected"`        // cnt of frequency slots in window
+	Open      *float64   `db:"open" json:"open"`         // first price
+	OpenAt    *time.Time `db:"open_at" json:"open_at"`   // time of first price
+	Close     *float64   `db:"close" json:"close"`       // last price
+	CloseAt   *time.Time `db:"close_at" json:"close_at"` // time of last price
+	Min       *float64   `db:"min" json:"min"`
+	MinAt     *time.Time `db:"min_at" json:"min_at"` // time of first min price
+	Max       *float64   `db:"max" json:"max"`
+	MaxAt     *time.Time `db:"max_at" json:"max_at"` // time of first max price
+	Mean      *float64   `db:"mean" json:"mean"`
+	TWA       *float64   `db:"twa" json:"twa"`       // time-weighted average, price lasts till next price (or end of window)
+	StdDev    *float64   `db:"stddev" json:"stddev"` // sample standard deviation (0 for one price)
+	P5        *float64   `db:"p5" json:"p5"`
+	P25       *float64   `db:"p25" json:"p25"`
+	P50       *float64   `db:"p50" json:"p50"`
+	P75       *float64   `db:"p75" json:"p75"`
+	P95       *float64   `db:"p95" json:"p95"`
+	Change    *float64   `db:"-" json:"change"`     // close - open
+	ChangePct *float64   `db:"-" json:"change_pct"` // change in percents of open (nil if open is 0)
+}
+
+// SummarizePrices - statistical summary of all prices of window [from, to], computed by db.
+func SummarizePrices(ctx context.Context, s Storage, currency model.CurrencyCode, from, to time.Time) (PriceSummary, error) {
+	var summary PriceSummary
+
+	if err := s.PureSqlxDB().QueryRowxContext(ctx, fmt.Sprintf(`
+WITH p AS (
+	SELECT time, price, extract(epoch FROM LEAD(time, 1, $2) OVER (ORDER BY time) - time) AS lasts
+	FROM %s WHERE time BETWEEN $1 AND $2
+)
+SELECT
+	count(*) AS samples,
+	first(price, time) AS open, min(time) AS open_at,
+	last(price, time) AS close, max(time) AS close_at,
+	min(price) AS min, first(time, price) AS min_at,
+	max(price) AS max, last(time, price) AS max_at,
+	avg(price) AS mean,
+	COALESCE(sum(price * lasts) / NULLIF(sum(lasts), 0), avg(price)) AS twa,
+	CASE WHEN count(*) > 0 THEN COALESCE(stddev_samp(price), 0) END AS stddev,
+	percentile_cont(0.05) WITHIN GROUP (ORDER BY price) AS p5,
+	percentile_cont(0.25) WITHIN GROUP (ORDER BY price) AS p25,
+	percentile_cont(0.5) WITHIN GROUP (ORDER BY price) AS p50,
+	percentile_cont(0.75) WITHIN GROUP (ORDER BY price) AS p75,
+	percentile_cont(0.95) WITHIN GROUP (ORDER BY price) AS p95
+FROM p`, model.PriceTableNameGetterFunc(currency)), from, to).StructScan(&summary); err != nil {
+		return summary, fmt.Errorf("can not summarize prices: %w", err)
+	}
+
+	summary.calcChange()
+
+	return summary, nil
+}
+
+func (ps *PriceSummary) calcChange() {
+	if ps.Open == nil || ps.Close == nil {
+		return
+	}
+
+	change := *ps.Close - *ps.Open
+	ps.Change = &change
+
+	if *ps.Open != 0 {
+		pct := change / *ps.Open * 100
+		ps.ChangePct = &pct
+	}
+}
+
+// candlesOrigin - default origin of time_bucket (buckets of continuous aggregates are aligned to it).
+var candlesOrigin = time.Date(2000, 1, 3, 0, 0, 0, 0, time.UTC)
+
+// SelectCandles - at most limit OHLC candles of interval which buckets start in [from, to). Candles of common
+// intervals are read from continuous aggregates (model.CandleIntervals), others are computed on the fly by time_bucket.
+func SelectCandles(
+	ctx context.Context,
+	s Storage,
+	currency model.CurrencyCode,
+	interval time.Duration,
+	from, to time.Time,
+	limit uint64,
+) ([]model.Candle, error) {
+	query, args := candlesQuery(currency, interval, from, to, limit)
+
+	candles := make([]model.Candle, 0, limit)
+	if err := s.PureSqlxDB().SelectContext(ctx, &candles, query, args...); err != nil {
+		return nil, fmt.Errorf("can not select candles: %w", err)
+	}
+
+	return candles, nil
+}
+
+func candlesQuery(
+	currency model.CurrencyCode,
+	interval time.Duration,
+	from, to time.Time,
+	limit uint64,
+) (string, []any) {
+	// first buckets starting not earlier than from and to, so candles are not partial
+	from, to = alignUp(from, interval), alignUp(to, interval)
+
+	if suffix, ok := model.CandleIntervals[interval]; ok {
+		return fmt.Sprintf(`SELECT bucket, open, high, low, close, samples FROM %s `+
+			`WHERE bucket >= $1 AND bucket < $2 ORDER BY bucket LIMIT $3`,
+			model.CandlesViewNameGetterFunc(currency, suffix)), []any{from, to, limit}
+	}
+
+	return fmt.Sprintf(`SELECT time_bucket(make_interval(secs => $4), time) AS bucket, `+
+		`first(price, time) AS open, max(price) AS high, min(price) AS low, last(price, time) AS close, `+
+		`count(*) AS samples FROM %s WHERE time >= $1 AND time < $2 GROUP BY bucket ORDER BY bucket LIMIT $3`,
+		model.PriceTableNameGetterFunc(currency)), []any{from, to, limit, interval.Seconds()}
+}
+
+// alignUp - the first start of bucket of interval not earlier than t.
+func alignUp(t time.Time, interval time.Duration) time.Time {
+	d := t.Sub(candlesOrigin) % interval
+	if d == 0 {
+		return t
+	}
+
+	if d < 0 {
+		return t.Add(-d)
+	}
+
+	return t.Add(interval - d)
+}
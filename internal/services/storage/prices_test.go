@@ -0,0 +1,148 @@
+package storage
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_downsampleQuery(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	segments := []PriceSegment{
+		{From: start, To: start.Add(time.Minute), Freq: 5 * time.Second},
+		{From: start.Add(time.Minute), To: start.Add(time.Hour), Freq: time.Second},
+	}
+
+	query, args, err := downsampleQuery("btcusd_prices", segments[:1], AggFirst, time.Time{}, time.Time{}, 10,
+		false)
+	require.NoError(t, err)
+	assert.Equal(t, "SELECT bucket, time, price FROM ((SELECT bucket, min(time) AS time, first(price, time) AS price "+
+		"FROM (SELECT time_bucket(make_interval(secs => $3), time, $4::timestamp) AS bucket, time, price "+
+		"FROM btcusd_prices WHERE time >= $1 AND time <= $2) b GROUP BY bucket)) r ORDER BY bucket LIMIT $5", query)
+	assert.Equal(t, []any{start, start.Add(time.Minute), 5.0, start, uint64(10)}, args)
+
+	after, before := start.Add(time.Minute), start.Add(2*time.Minute)
+
+	query, args, err = downsampleQuery("btcusd_prices", segments, AggClosest, after, before, 10, false)
+	require.NoError(t, err)
+	assert.Contains(t, query, "time >= $1 AND time < $2 AND time >= $3 AND time < $4")
+	assert.Contains(t, query, "time >= $8 AND time <= $9 AND time >= $10 AND time < $11")
+	assert.Contains(t, query, " UNION ALL ")
+	assert.Contains(t, query, "SELECT bucket + make_interval(secs => $7) AS bucket")
+	assert.Contains(t, query, "ORDER BY bucket, abs(extract(epoch FROM time - bucket) - $7)) c")
+	assert.Contains(t, query, ") r WHERE bucket > $15 AND bucket < $16 ORDER BY bucket LIMIT $17")
+	assert.Equal(t, after.Add(-2500*time.Millisecond), args[2]) // start of bucket of grid point after "after"
+	assert.Equal(t, before.Add(5*time.Second), args[3])
+	assert.Equal(t, start.Add(-2500*time.Millisecond), args[5]) // origin of first segment centered on grid points
+	assert.Len(t, args, 17)
+
+	query, _, err = downsampleQuery("btcusd_prices", segments, AggAvg, time.Time{}, before, 10, true)
+	require.NoError(t, err)
+	assert.Contains(t, query, ") r WHERE bucket < $11 ORDER BY bucket DESC LIMIT $12")
+
+	_, _, err = downsampleQuery("btcusd_prices", segments, "median", time.Time{}, time.Time{}, 10, false)
+	assert.ErrorIs(t, err, ErrBadAggregation)
+
+	_, _, err = downsampleQuery("btcusd_prices", nil, AggAvg, time.Time{}, time.Time{}, 10, false)
+	assert.Error(t, err)
+}
+
+func TestPriceSteps(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(sec int) time.Time { return start.Add(time.Duration(sec) * time.Second) }
+	segments := []PriceSegment{
+		{From: at(0), To: at(10), Freq: 5 * time.Second},
+		{From: at(10), To: at(16), Freq: 3 * time.Second},
+	}
+
+	assert.Equal(t, []time.Time{at(0), at(5), at(10), at(13), at(16)}, PriceSteps(segments, time.Time{}, 100))
+	assert.Equal(t, []time.Time{at(0), at(5)}, PriceSteps(segments, time.Time{}, 2))
+	assert.Equal(t, []time.Time{at(10), at(13)}, PriceSteps(segments, at(5), 2))
+	assert.Equal(t, []time.Time{at(16)}, PriceSteps(segments, at(13), 2))
+	assert.Empty(t, PriceSteps(segments, at(16), 2))
+}
+
+func TestPriceStepsBefore(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(sec int) time.Time { return start.Add(time.Duration(sec) * time.Second) }
+	segments := []PriceSegment{
+		{From: at(0), To: at(10), Freq: 5 * time.Second},
+		{From: at(10), To: at(16), Freq: 3 * time.Second},
+	}
+
+	assert.Equal(t, []time.Time{at(0), at(5), at(10), at(13), at(16)}, PriceStepsBefore(segments, at(100), 100))
+	assert.Equal(t, []time.Time{at(13), at(16)}, PriceStepsBefore(segments, at(16), 2))
+	assert.Equal(t, []time.Time{at(5), at(10)}, PriceStepsBefore(segments, at(12), 2))
+	assert.Equal(t, []time.Time{at(0), at(5)}, PriceStepsBefore(segments, at(9), 100), "to is grid point of next")
+	assert.Empty(t, PriceStepsBefore(segments, start.Add(-time.Second), 2))
+}
+
+func TestPriceStepOf(t *testing.T) {
+	start := time.Date(2022, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(ms int) time.Time { return start.Add(time.Duration(ms) * time.Millisecond) }
+	segments := []PriceSegment{
+		{From: at(0), To: at(10000), Freq: 5 * time.Second},
+		{From: at(10000), To: at(16000), Freq: 2 * time.Second},
+	}
+
+	for _, tc := range []struct {
+		agg      string
+		t, point time.Time
+	}{
+		{agg: AggFirst, t: at(4999), point: at(0)},
+		{agg: AggFirst, t: at(11999), point: at(10000)},
+		{agg: AggClosest, t: at(2499), point: at(0)},
+		{agg: AggClosest, t: at(2500), point: at(5000)},
+		{agg: AggClosest, t: at(9999), point: at(10000)},
+		{agg: AggClosest, t: at(10500), point: at(10000)},
+		{agg: AggClosest, t: at(11000), point: at(12000)},
+	} {
+		point, ok := PriceStepOf(segments, tc.agg, tc.t)
+		assert.True(t, ok)
+		assert.Equal(t, tc.point, point, tc)
+	}
+
+	_, ok := PriceStepOf(segments, AggFirst, at(16001))
+	assert.False(t, ok)
+}
+
+func TestPriceSummary_calcChange(t *testing.T) {
+	price := func(v float64) *float64 { return &v }
+
+	ps := PriceSummary{Open: price(200), Close: price(250)}
+	ps.calcChange()
+	assert.Equal(t, price(50), ps.Change)
+	assert.Equal(t, price(25), ps.ChangePct)
+
+	ps = PriceSummary{Open: price(0), Close: price(1)}
+	ps.calcChange()
+	assert.Equal(t, price(1), ps.Change)
+	assert.Nil(t, ps.ChangePct)
+
+	ps = PriceSummary{}
+	ps.calcChange()
+	assert.Nil(t, ps.Change)
+}
+
+func Test_candlesQuery(t *testing.T) {
+	from := time.Date(2022, 1, 1, 10, 0, 30, 0, time.UTC)
+	to := time.Date(2022, 1, 1, 11, 0, 0, 0, time.UTC)
+
+	query, args := candlesQuery("BTCUSD", time.Minute, from, to, 100)
+	assert.Equal(t, "SELECT bucket, open, high, low, close, samples FROM btcusd_candles_1m "+
+		"WHERE bucket >= $1 AND bucket < $2 ORDER BY bucket LIMIT $3", query)
+	assert.Equal(t, []any{time.Date(2022, 1, 1, 10, 1, 0, 0, time.UTC), to, uint64(100)}, args)
+
+	query, args = candlesQuery("BTCUSD", 15*time.Minute, from, to, 100)
+	assert.Contains(t, query, "FROM btcusd_prices WHERE time >= $1 AND time < $2 GROUP BY bucket")
+	assert.Equal(t, []any{time.Date(2022, 1, 1, 10, 15, 0, 0, time.UTC), to, uint64(100), 900.0}, args)
+}
+
+func Test_alignUp(t *testing.T) {
+	assert.Equal(t, time.Date(2022, 1, 3, 0, 0, 0, 0, time.UTC), // Monday, as origin of time_bucket
+		alignUp(time.Date(2021, 12, 29, 0, 0, 0, 0, time.UTC), 7*24*time.Hour))
+	assert.Equal(t, time.Date(1999, 1, 1, 0, 0, 0, 0, time.UTC),
+		alignUp(time.Date(1998, 12, 31, 12, 0, 0, 0, time.UTC), 24*time.Hour))
+}
@@ -94,6 +94,10 @@ type (
 	Lt = squirrel.Lt
 	// Gt - and pred.
 	Gt = squirrel.Gt
+	// LtOrEq - and pred.
+	LtOrEq = squirrel.LtOrEq
+	// GtOrEq - and pred.
+	GtOrEq = squirrel.GtOrEq
 
 	// CursorPaginationParams alias of CursorPaginationParams.
 	CursorPaginationParams = db.CursorPaginationParams
@@ -0,0 +1,60 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/imperiuse/price_monitor/internal/services/storage/model"
+)
+
+// ReasonTemplateRun - reason of creation of monitoring materialized from template.
+const ReasonTemplateRun = "created by template schedule"
+
+// MaterializeTemplateRun - create scheduled monitoring (run of template) with window [runAt, runAt + period) and move
+// next_run_at of template to nextRunAt in one statement. Nothing is done if template is paused or its next_run_at is
+// not t.NextRunAt anymore (run has already been materialized). Return id of created monitoring or 0.
+func MaterializeTemplateRun(
+	ctx context.Context,
+	s Storage,
+	t model.MonitoringTemplate,
+	runAt time.Time,
+	period time.Duration,
+	nextRunAt time.Time,
+) (model.Identity, error) {
+	var id model.Identity
+
+	now := time.Now().UTC()
+
+	err := s.PureSqlxDB().QueryRowxContext(ctx, fmt.Sprintf(`
+WITH tpl AS (
+	UPDATE %[1]s SET next_run_at = $3, last_run_at = $8
+	WHERE id = $1 AND next_run_at = $2 AND NOT paused
+	RETURNING id, currency_id, frequency
+), m AS (
+	INSERT INTO %[2]s(created_at, started_at, expired_at, frequency, currency_id, status, status_changed_at, template_id)
+	SELECT $4, $8, $5, frequency, currency_id, $6, $4, id FROM tpl
+	RETURNING id, started_at, expired_at, frequency, status
+), tr AS (
+	INSERT INTO %[3]s(monitoring_id, from_status, to_status, reason, created_at)
+	SELECT id, '', status, $7, $4 FROM m
+)
+INSERT INTO %[4]s(monitoring_id, created_at, started_at, expired_at, frequency, effective_from)
+SELECT id, $4, started_at, expired_at, frequency, started_at FROM m
+RETURNING monitoring_id`,
+		model.MonitoringTemplate{}.Repo(), model.Monitoring{}.Repo(), model.MonitoringTransition{}.Repo(),
+		model.MonitoringChange{}.Repo()),
+		t.ID, t.NextRunAt, nextRunAt, now, runAt.Add(period), model.StatusScheduled, ReasonTemplateRun, runAt,
+	).Scan(&id)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return 0, nil
+		}
+
+		return 0, fmt.Errorf("can not materialize run of template: %w", err)
+	}
+
+	return id, nil
+}
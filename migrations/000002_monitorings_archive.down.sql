@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx__monitorings__currency_id__started_at;
+DROP INDEX IF EXISTS idx__monitorings__expired_at;
+DROP TABLE IF EXISTS monitorings_archive;
+
+COMMIT;
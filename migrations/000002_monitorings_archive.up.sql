@@ -0,0 +1,15 @@
+BEGIN;
+
+-- Archive of expired monitorings (moved here by cleaner controller in "archive" mode)
+CREATE TABLE IF NOT EXISTS monitorings_archive(
+    id           INTEGER      PRIMARY KEY,
+    archived_at  TIMESTAMP    NOT NULL DEFAULT NOW(),
+    data         JSONB        NOT NULL -- row of monitorings table as is
+);
+COMMENT ON TABLE monitorings_archive IS 'Table for archived expired monitorings';
+
+-- cleaner searches expired monitorings and monitorings which cover prices
+CREATE INDEX IF NOT EXISTS idx__monitorings__expired_at ON monitorings(expired_at);
+CREATE INDEX IF NOT EXISTS idx__monitorings__currency_id__started_at ON monitorings(currency_id, started_at);
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE IF EXISTS monitoring_transitions;
+DROP INDEX IF EXISTS idx__monitorings__status;
+ALTER TABLE monitorings DROP COLUMN IF EXISTS status_changed_at, DROP COLUMN IF EXISTS status;
+
+COMMIT;
@@ -0,0 +1,30 @@
+BEGIN;
+
+-- Lifecycle status of monitoring: scheduled -> running -> completed | failed, scheduled | running -> cancelled
+ALTER TABLE monitorings
+    ADD COLUMN IF NOT EXISTS status            TEXT      NOT NULL DEFAULT 'scheduled',
+    ADD COLUMN IF NOT EXISTS status_changed_at TIMESTAMP NOT NULL DEFAULT NOW();
+
+-- already started monitorings are finished (completed or failed) by lifecycle controller
+UPDATE monitorings SET status = 'running' WHERE started_at <= NOW();
+
+CREATE INDEX IF NOT EXISTS idx__monitorings__status ON monitorings(status);
+
+-- Transitions of monitorings between statuses
+CREATE TABLE IF NOT EXISTS monitoring_transitions(
+    id             INTEGER      PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+    monitoring_id  INTEGER      NOT NULL,
+    from_status    TEXT         NOT NULL DEFAULT '', -- '' for creation of monitoring
+    to_status      TEXT         NOT NULL,
+    reason         TEXT         NOT NULL DEFAULT '',
+    created_at     TIMESTAMP    NOT NULL DEFAULT NOW(),
+
+    CONSTRAINT fkey__monitorings_id FOREIGN KEY (monitoring_id)
+        REFERENCES monitorings(id) MATCH SIMPLE
+        ON UPDATE NO ACTION ON DELETE CASCADE
+);
+COMMENT ON TABLE monitoring_transitions IS 'Table for history of monitoring status transitions';
+
+CREATE INDEX IF NOT EXISTS idx__monitoring_transitions__monitoring_id ON monitoring_transitions(monitoring_id);
+
+COMMIT;
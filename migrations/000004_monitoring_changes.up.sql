@@ -0,0 +1,23 @@
+BEGIN;
+
+-- History of changes of monitorings (state of monitoring after change), first record is made on creation.
+-- Frequency of record is effective from effective_from till effective_from of next record.
+CREATE TABLE IF NOT EXISTS monitoring_changes(
+    id              INTEGER      PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+    monitoring_id   INTEGER      NOT NULL,
+    created_at      TIMESTAMP    NOT NULL DEFAULT NOW(),
+
+    started_at      TIMESTAMP    NOT NULL,
+    expired_at      TIMESTAMP    NOT NULL,
+    frequency       TEXT         NOT NULL,
+    effective_from  TIMESTAMP    NOT NULL,
+
+    CONSTRAINT fkey__monitorings_id FOREIGN KEY (monitoring_id)
+        REFERENCES monitorings(id) MATCH SIMPLE
+        ON UPDATE NO ACTION ON DELETE CASCADE
+);
+COMMENT ON TABLE monitoring_changes IS 'Table for history of monitoring changes';
+
+CREATE INDEX IF NOT EXISTS idx__monitoring_changes__monitoring_id ON monitoring_changes(monitoring_id);
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx__monitorings__template_id;
+ALTER TABLE monitorings DROP COLUMN IF EXISTS template_id;
+DROP TABLE IF EXISTS monitoring_templates;
+
+COMMIT;
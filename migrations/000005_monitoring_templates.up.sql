@@ -0,0 +1,32 @@
+BEGIN;
+
+-- Templates of recurring monitorings, concrete monitorings are materialized from them by cron schedule
+CREATE TABLE IF NOT EXISTS monitoring_templates(
+    id           INTEGER      PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+    created_at   TIMESTAMP    NOT NULL DEFAULT NOW(),
+
+    cron         TEXT         NOT NULL, -- standard cron expression (5 fields or descriptor), CRON_TZ= prefix for time zone
+    period       TEXT         NOT NULL, -- window of every run -- 30m, 1h
+    frequency    TEXT         NOT NULL, -- 1s, 10s, 1m
+
+    currency_id  INTEGER      NOT NULL,
+
+    paused       BOOLEAN      NOT NULL DEFAULT FALSE,
+    next_run_at  TIMESTAMP    NOT NULL,
+    last_run_at  TIMESTAMP    NULL,
+
+    CONSTRAINT fkey__currencies_id FOREIGN KEY (currency_id)
+        REFERENCES currencies(id) MATCH SIMPLE
+        ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+COMMENT ON TABLE monitoring_templates IS 'Table for templates of recurring monitorings';
+
+CREATE INDEX IF NOT EXISTS idx__monitoring_templates__next_run_at ON monitoring_templates(next_run_at) WHERE NOT paused;
+
+-- Runs of templates
+ALTER TABLE monitorings ADD COLUMN IF NOT EXISTS template_id INTEGER NULL
+    CONSTRAINT fkey__monitoring_templates_id REFERENCES monitoring_templates(id) ON DELETE SET NULL;
+
+CREATE INDEX IF NOT EXISTS idx__monitorings__template_id ON monitorings(template_id);
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP INDEX IF EXISTS idx__monitorings__expired_at_id;
+DROP INDEX IF EXISTS idx__monitorings__created_at_id;
+DROP INDEX IF EXISTS idx__monitorings__labels;
+ALTER TABLE monitorings DROP COLUMN IF EXISTS labels;
+
+COMMIT;
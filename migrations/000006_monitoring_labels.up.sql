@@ -0,0 +1,12 @@
+BEGIN;
+
+-- Labels of monitorings (key -> value), used for search of monitorings
+ALTER TABLE monitorings ADD COLUMN IF NOT EXISTS labels JSONB NOT NULL DEFAULT '{}';
+
+CREATE INDEX IF NOT EXISTS idx__monitorings__labels ON monitorings USING GIN (labels jsonb_path_ops);
+
+-- Keyset pagination of monitorings list
+CREATE INDEX IF NOT EXISTS idx__monitorings__created_at_id ON monitorings(created_at, id);
+CREATE INDEX IF NOT EXISTS idx__monitorings__expired_at_id ON monitorings(expired_at, id);
+
+COMMIT;
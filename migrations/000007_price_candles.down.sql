@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP MATERIALIZED VIEW IF EXISTS btcusd_candles_1d;
+DROP MATERIALIZED VIEW IF EXISTS btcusd_candles_1h;
+DROP MATERIALIZED VIEW IF EXISTS btcusd_candles_1m;
+
+COMMIT;
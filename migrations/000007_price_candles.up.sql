@@ -0,0 +1,51 @@
+BEGIN;
+
+-- OHLC candles of prices for common intervals (continuous aggregates over hypertable from 000001_init),
+-- other intervals are computed on the fly by time_bucket.
+-- Real time aggregation (materialized_only = false) adds not yet materialized recent buckets.
+-- TODO FOR LATER other currencies (*_prices tables) need the same views
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS btcusd_candles_1m
+WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+SELECT time_bucket(INTERVAL '1 minute', time) AS bucket,
+       first(price, time) AS open,
+       max(price)         AS high,
+       min(price)         AS low,
+       last(price, time)  AS close,
+       count(*)           AS samples
+FROM btcusd_prices
+GROUP BY bucket
+WITH NO DATA;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS btcusd_candles_1h
+WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+SELECT time_bucket(INTERVAL '1 hour', time) AS bucket,
+       first(price, time) AS open,
+       max(price)         AS high,
+       min(price)         AS low,
+       last(price, time)  AS close,
+       count(*)           AS samples
+FROM btcusd_prices
+GROUP BY bucket
+WITH NO DATA;
+
+CREATE MATERIALIZED VIEW IF NOT EXISTS btcusd_candles_1d
+WITH (timescaledb.continuous, timescaledb.materialized_only = false) AS
+SELECT time_bucket(INTERVAL '1 day', time) AS bucket,
+       first(price, time) AS open,
+       max(price)         AS high,
+       min(price)         AS low,
+       last(price, time)  AS close,
+       count(*)           AS samples
+FROM btcusd_prices
+GROUP BY bucket
+WITH NO DATA;
+
+SELECT add_continuous_aggregate_policy('btcusd_candles_1m',
+    start_offset => INTERVAL '1 hour', end_offset => INTERVAL '1 minute', schedule_interval => INTERVAL '1 minute');
+SELECT add_continuous_aggregate_policy('btcusd_candles_1h',
+    start_offset => INTERVAL '1 day', end_offset => INTERVAL '1 hour', schedule_interval => INTERVAL '30 minutes');
+SELECT add_continuous_aggregate_policy('btcusd_candles_1d',
+    start_offset => INTERVAL '7 days', end_offset => INTERVAL '1 day', schedule_interval => INTERVAL '6 hours');
+
+COMMIT;
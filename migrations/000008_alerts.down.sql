@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS alert_events;
+DROP TABLE IF EXISTS alert_rules;
+
+COMMIT;
@@ -0,0 +1,43 @@
+BEGIN;
+
+-- Alert rules on prices, evaluated by scanner on every new sample
+CREATE TABLE IF NOT EXISTS alert_rules(
+    id            INTEGER          PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+    created_at    TIMESTAMP        NOT NULL DEFAULT NOW(),
+
+    currency_id   INTEGER          NOT NULL,
+
+    kind          TEXT             NOT NULL, -- cross_above, cross_below, change
+    threshold     DOUBLE PRECISION NOT NULL, -- price for cross rules, percent of move for change rule
+    move_window   TEXT             NOT NULL DEFAULT '', -- window of move for change rule -- 10m, 1h
+    hysteresis    DOUBLE PRECISION NOT NULL DEFAULT 0, -- distance from threshold to re-arm rule (price or percent)
+    cooldown      TEXT             NOT NULL DEFAULT '0s', -- min interval between fires -- 5m
+    channels      JSONB            NOT NULL DEFAULT '[]', -- names of notification channels (from config)
+
+    armed         BOOLEAN          NULL, -- NULL - rule has not been evaluated yet
+    last_fired_at TIMESTAMP        NULL,
+
+    CONSTRAINT fkey__currencies_id FOREIGN KEY (currency_id)
+        REFERENCES currencies(id) MATCH SIMPLE
+        ON UPDATE NO ACTION ON DELETE NO ACTION
+);
+COMMENT ON TABLE alert_rules IS 'Table for alert rules on prices';
+
+CREATE INDEX IF NOT EXISTS idx__alert_rules__currency_id ON alert_rules(currency_id);
+
+-- History of fired alerts (kept after deletion of rule)
+CREATE TABLE IF NOT EXISTS alert_events(
+    id            INTEGER          PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+    rule_id       INTEGER          NULL REFERENCES alert_rules(id) ON DELETE SET NULL,
+    currency_id   INTEGER          NOT NULL,
+    fired_at      TIMESTAMP        NOT NULL,
+    price         DOUBLE PRECISION NOT NULL, -- price of sample which fired rule
+    value         DOUBLE PRECISION NOT NULL, -- price for cross rules, percent of move for change rule
+    message       TEXT             NOT NULL
+);
+COMMENT ON TABLE alert_events IS 'Table for history of fired alerts';
+
+CREATE INDEX IF NOT EXISTS idx__alert_events__fired_at ON alert_events(fired_at);
+CREATE INDEX IF NOT EXISTS idx__alert_events__rule_id ON alert_events(rule_id);
+
+COMMIT;
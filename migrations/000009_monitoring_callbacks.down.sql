@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE IF EXISTS monitoring_callbacks;
+ALTER TABLE monitorings DROP COLUMN IF EXISTS callback_url;
+
+COMMIT;
@@ -0,0 +1,27 @@
+BEGIN;
+
+-- Url where result of monitoring is posted when it's finished
+ALTER TABLE monitorings ADD COLUMN IF NOT EXISTS callback_url TEXT NOT NULL DEFAULT '';
+
+-- Outbox of callbacks: created with monitoring, delivered by master node after monitoring is finished
+CREATE TABLE IF NOT EXISTS monitoring_callbacks(
+    id              INTEGER      PRIMARY KEY GENERATED BY DEFAULT AS IDENTITY,
+    monitoring_id   INTEGER      NOT NULL REFERENCES monitorings(id) ON DELETE CASCADE,
+    url             TEXT         NOT NULL,
+    created_at      TIMESTAMP    NOT NULL DEFAULT NOW(),
+
+    payload         JSONB        NULL, -- signed body, built on first attempt (the same for retries)
+    attempts        INTEGER      NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP    NOT NULL DEFAULT NOW(),
+    last_error      TEXT         NOT NULL DEFAULT '',
+
+    delivered_at    TIMESTAMP    NULL,
+    failed_at       TIMESTAMP    NULL -- max attempts were made, delivery is given up
+);
+COMMENT ON TABLE monitoring_callbacks IS 'Table for outbox of callbacks with results of monitorings';
+
+CREATE INDEX IF NOT EXISTS idx__monitoring_callbacks__next_attempt_at ON monitoring_callbacks(next_attempt_at)
+    WHERE delivered_at IS NULL AND failed_at IS NULL;
+CREATE INDEX IF NOT EXISTS idx__monitoring_callbacks__monitoring_id ON monitoring_callbacks(monitoring_id);
+
+COMMIT;
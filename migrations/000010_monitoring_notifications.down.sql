@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TRIGGER IF EXISTS trg__monitoring_transitions__notify_finished ON monitoring_transitions;
+DROP FUNCTION IF EXISTS notify_monitoring_finished();
+
+COMMIT;
@@ -0,0 +1,17 @@
+BEGIN;
+
+-- Notification about finish (final status) of monitoring, nodes listen it to wake up requests waiting for result.
+-- Every change of status is stored in monitoring_transitions, so all ways of finish are covered (lifecycle, cancel...)
+CREATE OR REPLACE FUNCTION notify_monitoring_finished() RETURNS TRIGGER AS $$
+BEGIN
+    PERFORM pg_notify('monitoring_finished', NEW.monitoring_id::TEXT);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE OR REPLACE TRIGGER trg__monitoring_transitions__notify_finished
+    AFTER INSERT ON monitoring_transitions
+    FOR EACH ROW WHEN (NEW.to_status IN ('completed', 'failed', 'cancelled'))
+    EXECUTE FUNCTION notify_monitoring_finished();
+
+COMMIT;
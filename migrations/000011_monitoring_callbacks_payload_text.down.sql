@@ -0,0 +1,5 @@
+BEGIN;
+
+ALTER TABLE monitoring_callbacks ALTER COLUMN payload TYPE JSONB USING payload::JSONB;
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+-- Payload is stored as is (JSONB reorders keys and reformats it), so every retry sends exactly the signed bytes
+-- of the first attempt.
+ALTER TABLE monitoring_callbacks ALTER COLUMN payload TYPE TEXT USING payload::TEXT;
+
+COMMIT;